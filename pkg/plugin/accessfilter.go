@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+)
+
+// errAccessDenied is returned for queries targeting a project or log bucket
+// excluded by the data source's project list or log bucket filters
+var errAccessDenied = errors.New("permission denied")
+
+// accessFilter restricts the projects and log buckets that can be queried,
+// from the `projectListFilter` and `logBucketFilter` settings also applied
+// by the query editor. Its zero value allows everything.
+type accessFilter struct {
+	// projects are the patterns of allowed project IDs. Any project is
+	// allowed if empty.
+	projects []*regexp.Regexp
+	// includeBuckets are the patterns of allowed buckets. Any bucket is
+	// allowed if empty.
+	includeBuckets []*regexp.Regexp
+	// excludeBuckets are the patterns of denied buckets, which take
+	// precedence over includeBuckets
+	excludeBuckets []*regexp.Regexp
+}
+
+// newAccessFilter parses the project list and log bucket filter settings.
+// Each non-empty line is a pattern matching whole project or bucket IDs, such
+// as `global/buckets/team-.*`. Bucket patterns prefixed with `!` exclude
+// buckets. Lines that aren't valid regular expressions match literally.
+func newAccessFilter(projectListFilter string, logBucketFilter string) accessFilter {
+	f := accessFilter{}
+	for _, line := range filterLines(projectListFilter) {
+		f.projects = append(f.projects, filterPattern(line))
+	}
+	for _, line := range filterLines(logBucketFilter) {
+		if pattern, ok := strings.CutPrefix(line, "!"); ok {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				f.excludeBuckets = append(f.excludeBuckets, filterPattern(pattern))
+			}
+		} else {
+			f.includeBuckets = append(f.includeBuckets, filterPattern(line))
+		}
+	}
+	return f
+}
+
+func filterLines(filter string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(filter, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func filterPattern(pattern string) *regexp.Regexp {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	return re
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, p := range patterns {
+		if p.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowProject reports whether the project can be queried
+func (f accessFilter) allowProject(projectID string) bool {
+	return len(f.projects) == 0 || matchesAny(f.projects, projectID)
+}
+
+// defaultResourceBuckets are the log buckets read from a project's default
+// resource, `projects/<id>`
+var defaultResourceBuckets = []string{"global/buckets/_Default", "global/buckets/_Required"}
+
+// allowBucket reports whether the log bucket can be queried. The empty
+// bucket is the project's default resource, which can only be queried if the
+// buckets it reads from can be.
+func (f accessFilter) allowBucket(bucketID string) bool {
+	if bucketID == "" {
+		for _, b := range defaultResourceBuckets {
+			if !f.allowBucket(b) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(f.includeBuckets) > 0 && !matchesAny(f.includeBuckets, bucketID) {
+		return false
+	}
+	return !matchesAny(f.excludeBuckets, bucketID)
+}
+
+// check returns an error wrapping errAccessDenied if the project or the
+// bucket, and so any of its views, can't be queried
+func (f accessFilter) check(projectID string, bucketID string) error {
+	if !f.allowProject(projectID) {
+		return fmt.Errorf("%w: project %q is not allowed by this data source", errAccessDenied, projectID)
+	}
+	if !f.allowBucket(bucketID) {
+		return fmt.Errorf("%w: log bucket %q is not allowed by this data source", errAccessDenied, bucketID)
+	}
+	return nil
+}
+
+// filterProjects removes the projects that can't be queried
+func (f accessFilter) filterProjects(projects []cloudlogging.Project) []cloudlogging.Project {
+	allowed := make([]cloudlogging.Project, 0, len(projects))
+	for _, p := range projects {
+		if f.allowProject(p.ID) {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+// filterBuckets removes the log buckets that can't be queried
+func (f accessFilter) filterBuckets(buckets []cloudlogging.LogBucket) []cloudlogging.LogBucket {
+	allowed := make([]cloudlogging.LogBucket, 0, len(buckets))
+	for _, b := range buckets {
+		if f.allowBucket(b.ID) {
+			allowed = append(allowed, b)
+		}
+	}
+	return allowed
+}
+
+// checkLogScope returns an error wrapping errAccessDenied unless all of the
+// projects and views of the log scope can be queried
+func (f accessFilter) checkLogScope(scope *cloudlogging.LogScope) error {
+	for _, name := range scope.ResourceNames {
+		r, err := cloudlogging.ParseScopeResource(name)
+		if err != nil {
+			return fmt.Errorf("log scope %q: %w", scope.ID, err)
+		}
+		if err := f.check(r.ProjectID, r.BucketId); err != nil {
+			return fmt.Errorf("log scope %q: %w", scope.ID, err)
+		}
+	}
+	return nil
+}
+
+// filterLogScopes removes the log scopes that can't be queried
+func (f accessFilter) filterLogScopes(scopes []cloudlogging.LogScope) []cloudlogging.LogScope {
+	allowed := make([]cloudlogging.LogScope, 0, len(scopes))
+	for _, s := range scopes {
+		if f.checkLogScope(&s) == nil {
+			allowed = append(allowed, s)
+		}
+	}
+	return allowed
+}
+
+// restrictedClient ANDs the data source's restriction filter into the filter
+// of every log entry query, see cloudlogging.Query.Restriction
+type restrictedClient struct {
+	cloudlogging.API
+	restriction string
+}
+
+// restrict wraps client to apply the restriction filter, if any
+func restrict(client cloudlogging.API, restriction string) cloudlogging.API {
+	if restriction == "" {
+		return client
+	}
+	return restrictedClient{API: client, restriction: restriction}
+}
+
+func (c restrictedClient) ListLogs(ctx context.Context, q *cloudlogging.Query) ([]*loggingpb.LogEntry, error) {
+	restricted := *q
+	restricted.Restriction = c.restriction
+	return c.API.ListLogs(ctx, &restricted)
+}
+
+func (c restrictedClient) ListLogPages(ctx context.Context, q *cloudlogging.Query, fn func([]*loggingpb.LogEntry) error) error {
+	restricted := *q
+	restricted.Restriction = c.restriction
+	return c.API.ListLogPages(ctx, &restricted, fn)
+}
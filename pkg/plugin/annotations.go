@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// defaultAnnotations is how many annotations are returned when no limit is given
+	defaultAnnotations = 100
+	// maxAnnotations is the maximum number of annotations returned
+	maxAnnotations = 1000
+)
+
+// annotationModel maps log entry fields to annotations in the annotations
+// query type
+type annotationModel struct {
+	TitleField     string   `json:"titleField,omitempty"`
+	TextField      string   `json:"textField,omitempty"`
+	TagFields      []string `json:"tagFields,omitempty"`
+	TimeEndField   string   `json:"timeEndField,omitempty"`
+	MaxAnnotations int      `json:"maxAnnotations,omitempty"`
+}
+
+// annotationOptions validates the annotation mapping of a query, which may be
+// missing to annotate log messages
+func annotationOptions(m *annotationModel) (cloudlogging.AnnotationOptions, error) {
+	opts := cloudlogging.AnnotationOptions{MaxAnnotations: defaultAnnotations}
+	if m == nil {
+		return opts, nil
+	}
+	opts.TitleField = strings.TrimSpace(m.TitleField)
+	opts.TextField = strings.TrimSpace(m.TextField)
+	opts.EndTimeField = strings.TrimSpace(m.TimeEndField)
+	for _, field := range m.TagFields {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.TagFields = append(opts.TagFields, field)
+		}
+	}
+	if m.MaxAnnotations > 0 {
+		opts.MaxAnnotations = m.MaxAnnotations
+	}
+	if opts.MaxAnnotations > maxAnnotations {
+		opts.MaxAnnotations = maxAnnotations
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("annotations: %w", err)
+	}
+	return opts, nil
+}
+
+// annotationsFrame builds a frame with the columns Grafana reads annotations
+// from, with tags separated by commas
+func annotationsFrame(annotations []cloudlogging.Annotation, total int) *data.Frame {
+	frame := data.NewFrame("annotations",
+		data.NewField("time", nil, []time.Time{}),
+		data.NewField("timeEnd", nil, []*time.Time{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("text", nil, []string{}),
+		data.NewField("tags", nil, []string{}),
+	)
+	for _, a := range annotations {
+		frame.AppendRow(a.Time, a.TimeEnd, a.Title, a.Text, strings.Join(a.Tags, ","))
+	}
+
+	frame.Meta = &data.FrameMeta{}
+	if total > len(annotations) {
+		frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("Showing %d of %d annotations", len(annotations), total),
+		})
+	}
+	return frame
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// AnnotationOptions map the fields of log entries to annotations. Fields are
+// referenced by path as in FieldValues.
+type AnnotationOptions struct {
+	// TitleField is the field of the annotation title, none if empty
+	TitleField string
+	// TextField is the field of the annotation text, the log message if empty
+	TextField string
+	// TagFields are the fields whose values are the annotation tags
+	TagFields []string
+	// EndTimeField is the field of the end time of region annotations, as an
+	// RFC 3339 timestamp or seconds or milliseconds since the epoch
+	EndTimeField string
+	// MaxAnnotations is the maximum number of annotations, unlimited if zero
+	MaxAnnotations int
+}
+
+// Validate checks the field paths of the options
+func (o AnnotationOptions) Validate() error {
+	fields := append([]string{o.TitleField, o.TextField, o.EndTimeField}, o.TagFields...)
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		if err := ValidateFieldPath(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Annotation is an event marking a point or, if TimeEnd is set, a region of
+// time on graphs
+type Annotation struct {
+	Time    time.Time
+	TimeEnd *time.Time
+	Title   string
+	Text    string
+	Tags    []string
+}
+
+// key identifies annotations with the same content
+func (a Annotation) key() string {
+	end := ""
+	if a.TimeEnd != nil {
+		end = strconv.FormatInt(a.TimeEnd.UnixNano(), 10)
+	}
+	return strings.Join([]string{
+		strconv.FormatInt(a.Time.UnixNano(), 10), end, a.Title, a.Text, strings.Join(a.Tags, ","),
+	}, "\x00")
+}
+
+// BuildAnnotations maps the given entries to annotations in order, dropping
+// duplicates such as an event logged by several replicas, and returns up to
+// MaxAnnotations of them along with the number of distinct annotations.
+func BuildAnnotations(entries []*loggingpb.LogEntry, opts AnnotationOptions) ([]Annotation, int) {
+	annotations := []Annotation{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		a := Annotation{
+			Time: entry.GetTimestamp().AsTime(),
+			Tags: []string{},
+		}
+		if opts.TitleField != "" {
+			a.Title, _ = fieldValue(entry, opts.TitleField)
+		}
+		if opts.TextField != "" {
+			a.Text, _ = fieldValue(entry, opts.TextField)
+		} else {
+			a.Text, _ = GetLogEntryMessage(entry)
+		}
+		for _, field := range opts.TagFields {
+			if tag, ok := fieldValue(entry, field); ok && tag != "" {
+				a.Tags = append(a.Tags, tag)
+			}
+		}
+		if opts.EndTimeField != "" {
+			if value, ok := fieldValue(entry, opts.EndTimeField); ok {
+				if end, err := parseEndTime(value); err == nil && end.After(a.Time) {
+					a.TimeEnd = &end
+				}
+			}
+		}
+
+		key := a.key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		annotations = append(annotations, a)
+	}
+
+	total := len(annotations)
+	if opts.MaxAnnotations > 0 && total > opts.MaxAnnotations {
+		annotations = annotations[:opts.MaxAnnotations]
+	}
+	return annotations, total
+}
+
+// parseEndTime parses an RFC 3339 timestamp, or a number of seconds since the
+// epoch, or of milliseconds if too large to be seconds
+func parseEndTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return time.Time{}, fmt.Errorf("invalid end time %q", value)
+	}
+	// Seconds since the epoch pass 1e11 in the year 5138
+	if n >= 1e11 {
+		return time.UnixMilli(int64(n)).UTC(), nil
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
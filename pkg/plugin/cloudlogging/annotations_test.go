@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBuildAnnotations(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deploy := func(insertID string, version string, end any) *loggingpb.LogEntry {
+		payload, err := structpb.NewStruct(map[string]any{
+			"message": "deployed " + version,
+			"deploy":  map[string]any{"version": version, "end": end},
+		})
+		require.NoError(t, err)
+		return &loggingpb.LogEntry{
+			InsertId:  insertID,
+			Timestamp: timestamppb.New(start),
+			Labels:    map[string]string{"env": "prod", "team": "payments"},
+			Payload:   &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+		}
+	}
+	entries := []*loggingpb.LogEntry{
+		deploy("a", "v2", start.Add(5*time.Minute).Format(time.RFC3339)),
+		// The same event logged by another replica
+		deploy("b", "v2", start.Add(5*time.Minute).Format(time.RFC3339)),
+		deploy("c", "v1", float64(start.Add(time.Minute).Unix())),
+		// End times before the start are ignored
+		deploy("d", "v0", "2020-01-01T00:00:00Z"),
+	}
+
+	annotations, total := cloudlogging.BuildAnnotations(entries, cloudlogging.AnnotationOptions{
+		TitleField:   "jsonPayload.deploy.version",
+		TagFields:    []string{"labels.env", "labels.team", "labels.missing"},
+		EndTimeField: "jsonPayload.deploy.end",
+	})
+	require.Equal(t, 3, total)
+	require.Len(t, annotations, 3)
+
+	require.Equal(t, "v2", annotations[0].Title)
+	require.Equal(t, "deployed v2", annotations[0].Text)
+	require.Equal(t, []string{"prod", "payments"}, annotations[0].Tags)
+	require.Equal(t, start, annotations[0].Time)
+	require.NotNil(t, annotations[0].TimeEnd)
+	require.Equal(t, start.Add(5*time.Minute), *annotations[0].TimeEnd)
+
+	require.Equal(t, "v1", annotations[1].Title)
+	require.NotNil(t, annotations[1].TimeEnd)
+	require.Equal(t, start.Add(time.Minute), *annotations[1].TimeEnd)
+
+	require.Equal(t, "v0", annotations[2].Title)
+	require.Nil(t, annotations[2].TimeEnd)
+
+	capped, total := cloudlogging.BuildAnnotations(entries, cloudlogging.AnnotationOptions{
+		TextField:      "jsonPayload.deploy.version",
+		MaxAnnotations: 2,
+	})
+	require.Equal(t, 3, total)
+	require.Len(t, capped, 2)
+	require.Equal(t, "", capped[0].Title)
+	require.Equal(t, "v2", capped[0].Text)
+	require.Empty(t, capped[0].Tags)
+}
+
+func TestAnnotationOptionsValidate(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, cloudlogging.AnnotationOptions{}.Validate())
+	require.NoError(t, cloudlogging.AnnotationOptions{
+		TitleField: "jsonPayload.deploy.version",
+		TagFields:  []string{`labels."k8s-pod/app"`},
+	}.Validate())
+	require.ErrorContains(t, cloudlogging.AnnotationOptions{
+		TagFields: []string{"labels.env OR true"},
+	}.Validate(), "invalid field path")
+}
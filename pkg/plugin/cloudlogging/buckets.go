@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// LogBucket is the configuration of a log bucket
+type LogBucket struct {
+	// ID is the bucket as used in queries, `global/buckets/my-bucket` for
+	// `projects/my-project/locations/global/buckets/my-bucket`
+	ID          string `json:"id"`
+	Location    string `json:"location"`
+	Description string `json:"description,omitempty"`
+	// RetentionDays is how long entries are kept, entries older than that
+	// can't be queried
+	RetentionDays int32 `json:"retentionDays"`
+	Locked        bool  `json:"locked"`
+	// AnalyticsEnabled is set for buckets that can be queried with SQL
+	AnalyticsEnabled bool `json:"analyticsEnabled"`
+	// LifecycleState is ACTIVE unless the bucket is being created, updated or
+	// deleted, such as DELETE_REQUESTED
+	LifecycleState string `json:"lifecycleState"`
+	// KmsKeyName is the Cloud KMS key entries are encrypted with (CMEK), if any
+	KmsKeyName string `json:"kmsKeyName,omitempty"`
+	// LinkedDatasets are the BigQuery datasets linked to an analytics
+	// enabled bucket, as `bigquery.googleapis.com/projects/my-project/datasets/my_dataset`
+	LinkedDatasets []string `json:"linkedDatasets,omitempty"`
+}
+
+// LogView is the configuration of a view of a log bucket
+type LogView struct {
+	// ID is the view as used in queries, `my-view` for
+	// `projects/my-project/locations/global/buckets/my-bucket/views/my-view`
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	// Filter restricts the entries of the bucket the view includes
+	Filter string `json:"filter,omitempty"`
+}
+
+// logBucketFromProto converts a log bucket configuration
+func logBucketFromProto(b *loggingpb.LogBucket) LogBucket {
+	// See response format: https://cloud.google.com/logging/docs/reference/v2/rest/v2/billingAccounts.locations.buckets#LogBucket
+	name := strings.Split(b.GetName(), "/")
+	bucket := LogBucket{
+		Description:      b.GetDescription(),
+		RetentionDays:    b.GetRetentionDays(),
+		Locked:           b.GetLocked(),
+		AnalyticsEnabled: b.GetAnalyticsEnabled(),
+		LifecycleState:   b.GetLifecycleState().String(),
+		KmsKeyName:       b.GetCmekSettings().GetKmsKeyName(),
+	}
+	if len(name) >= 6 {
+		// Get `global/buckets/my-bucket` for `projects/my-project/locations/global/buckets/my-bucket`
+		bucket.ID = strings.Join(name[3:], "/")
+		bucket.Location = name[3]
+	}
+	return bucket
+}
+
+// logViewFromProto converts a log view configuration
+func logViewFromProto(v *loggingpb.LogView) LogView {
+	// See response format: https://cloud.google.com/logging/docs/reference/v2/rest/v2/billingAccounts.locations.buckets.views#LogView
+	name := strings.Split(v.GetName(), "/")
+	return LogView{
+		ID:          name[len(name)-1],
+		Description: v.GetDescription(),
+		Filter:      v.GetFilter(),
+	}
+}
@@ -18,10 +18,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math"
+	"sort"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	logging "cloud.google.com/go/logging/apiv2"
 	resourcemanager "cloud.google.com/go/resourcemanager/apiv3"
 	resourcemanagerpb "cloud.google.com/go/resourcemanager/apiv3/resourcemanagerpb"
@@ -29,7 +30,9 @@ import (
 	"golang.org/x/oauth2"
 	"google.golang.org/api/impersonate"
 	"google.golang.org/api/iterator"
+	loggingrest "google.golang.org/api/logging/v2"
 	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
 
 	// Currently, LogEntry.ProtoPayload only supports two types
 	// https://pkg.go.dev/cloud.google.com/go/logging/apiv2/loggingpb#LogEntry_ProtoPayload
@@ -42,29 +45,60 @@ import (
 
 const testConnectionTimeout = time.Minute * 1
 
+// readOnlyScope is the OAuth scope of the data source's credentials
+const readOnlyScope = "https://www.googleapis.com/auth/cloud-platform.read-only"
+
+// ErrNoEntries is returned by TestConnection for projects without log entries
+var ErrNoEntries = errors.New("no entries")
+
+// MaxPageSize is the maximum number of log entries per page of results
+const MaxPageSize = 1000
+
 // API implements the methods we need to query logs and list projects from GCP
 type API interface {
 	// ListLogs retrieves all logs matching some query filter up to the given limit
 	ListLogs(context.Context, *Query) ([]*loggingpb.LogEntry, error)
+	// ListLogPages retrieves logs matching some query filter up to the given
+	// limit one page at a time, stopping at the first error of fn
+	ListLogPages(ctx context.Context, q *Query, fn func([]*loggingpb.LogEntry) error) error
 	// TestConnection queries for any log from the given project
 	TestConnection(ctx context.Context, projectID string) error
-	// ListProjects returns the project IDs of all visible projects.
-	// If query is non-empty it is forwarded to the Resource Manager search filter.
-	ListProjects(ctx context.Context, query string) ([]string, error)
+	// TestToken acquires an access token with the client's credentials
+	TestToken(ctx context.Context) error
+	// TestPermissions returns which of the permissions the client's
+	// credentials have on the given project
+	TestPermissions(ctx context.Context, projectID string, permissions []string) ([]string, error)
+	// ListProjects returns a page of the active projects matching the query
+	ListProjects(ctx context.Context, query ProjectQuery) (*ProjectPage, error)
 	// ListProjectBuckets returns all log buckets of a project
-	ListProjectBuckets(ctx context.Context, projectId string) ([]string, error)
+	ListProjectBuckets(ctx context.Context, projectId string) ([]LogBucket, error)
 	// ListProjectBucketViews returns all views of a log bucket
-	ListProjectBucketViews(ctx context.Context, projectId string, bucketId string) ([]string, error)
+	ListProjectBucketViews(ctx context.Context, projectId string, bucketId string) ([]LogView, error)
+	// ListLogNames returns the names of the logs with entries in a project,
+	// or in a view of one of its log buckets if bucketId is set
+	ListLogNames(ctx context.Context, projectId string, bucketId string, viewId string) ([]string, error)
+	// ListMonitoredResourceTypes returns the types of all monitored resources
+	ListMonitoredResourceTypes(ctx context.Context) ([]string, error)
+	// ListLogScopes returns all log scopes of a project
+	ListLogScopes(ctx context.Context, projectId string) ([]LogScope, error)
+	// GetLogScope returns a log scope of a project
+	GetLogScope(ctx context.Context, projectId string, scopeId string) (*LogScope, error)
 	// Close closes the underlying connection to the GCP API
 	Close() error
 }
 
 // Client wraps a GCP logging client to fetch logs, a resourcemanager client
-// to list projects, and a config client to get log bucket configurations
+// to list projects, a config client to get log bucket configurations, and a
+// REST logging client for log scopes, which the gRPC clients don't support
 type Client struct {
 	lClient      *logging.Client
 	rClient      *resourcemanager.ProjectsClient
 	configClient *logging.ConfigClient
+	restClient   *loggingrest.Service
+	// opts are the options the clients were created with, for TestToken
+	opts []option.ClientOption
+	// pageLimiter spaces out the page requests of log entry reads
+	pageLimiter *rateLimiter
 }
 
 func universeDomainOpts(universeDomain string) []option.ClientOption {
@@ -93,10 +127,20 @@ func newClientFromOpts(ctx context.Context, opts []option.ClientOption) (*Client
 		lClient.Close()
 		return nil, err
 	}
+	restClient, err := loggingrest.NewService(ctx, opts...)
+	if err != nil {
+		configClient.Close()
+		rClient.Close()
+		lClient.Close()
+		return nil, err
+	}
 	return &Client{
 		lClient:      lClient,
 		rClient:      rClient,
 		configClient: configClient,
+		restClient:   restClient,
+		opts:         opts,
+		pageLimiter:  newRateLimiter(pageRequestInterval, pageRequestBurst),
 	}, nil
 }
 
@@ -129,13 +173,13 @@ func NewClientWithImpersonation(ctx context.Context, jsonCreds []byte, impersona
 	if jsonCreds == nil {
 		ts, err = impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
 			TargetPrincipal: impersonateSA,
-			Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform.read-only"},
+			Scopes:          []string{readOnlyScope},
 		}, impersonateOpts...)
 	} else {
 		impersonateOpts = append(impersonateOpts, option.WithCredentialsJSON(jsonCreds))
 		ts, err = impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
 			TargetPrincipal: impersonateSA,
-			Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform.read-only"},
+			Scopes:          []string{readOnlyScope},
 		}, impersonateOpts...)
 	}
 	if err != nil {
@@ -189,6 +233,12 @@ func (c *Client) Close() error {
 	return c.lClient.Close()
 }
 
+// Sort orders of log entries by timestamp
+const (
+	OrderDescending = "desc"
+	OrderAscending  = "asc"
+)
+
 // Query is the information from a Grafana query needed to query GCP for logs
 type Query struct {
 	ProjectID string
@@ -200,55 +250,90 @@ type Query struct {
 		From string
 		To   string
 	}
+	// Order is the timestamp sort order, OrderDescending if empty
+	Order string
+	// Restriction is a filter ANDed with the query text that the query text
+	// can't override, limiting the entries a data source can read
+	Restriction string
+	// ResourceNames are the projects and views to read from instead of the
+	// ProjectID, BucketId and ViewId, such as those of a log scope
+	ResourceNames []string
+}
+
+// resourceNames are the names of the resources the query reads from
+func (q *Query) resourceNames() []string {
+	if len(q.ResourceNames) > 0 {
+		return q.ResourceNames
+	}
+	if q.BucketId == "" {
+		return []string{legacyProjectResourceName(q.ProjectID)}
+	}
+	return []string{projectResourceName(q.ProjectID, q.BucketId, q.ViewId)}
 }
 
 // String is the query formatted for querying GCP
-// It is the query text, with the time range constraints appended
+// It is the query text, with the restriction and time range constraints
+// appended. The query text is parenthesized so that its operators, such as a
+// top-level OR, only apply within it. Filters made only of whitespace and
+// comments are left out, as empty parentheses aren't valid. The query text is
+// expected to be valid, see ValidateFilter.
 func (q *Query) String() string {
-	return fmt.Sprintf(`%s AND timestamp >= "%s" AND timestamp <= "%s"`,
-		q.Filter, q.TimeRange.From, q.TimeRange.To,
-	)
+	terms := []string{}
+	for _, filter := range []string{q.Restriction, q.Filter} {
+		filter = strings.TrimSpace(filter)
+		if expr, err := ParseFilter(filter); err != nil || expr != nil {
+			terms = append(terms, parenthesize(filter))
+		}
+	}
+	terms = append(terms, fmt.Sprintf(`timestamp >= "%s" AND timestamp <= "%s"`, q.TimeRange.From, q.TimeRange.To))
+	return strings.Join(terms, " AND ")
 }
 
-// ListProjects returns the project IDs of all visible projects.
-// If query is non-empty it is forwarded to the Resource Manager search filter
-// (e.g. "id:*term* OR name:*term*"). Results are capped at maxProjects.
-const maxProjects = 100
+// parenthesize wraps a filter in parentheses. The closing parenthesis goes on
+// its own line, since a `--` comment on the last line of the filter would
+// swallow it otherwise.
+func parenthesize(filter string) string {
+	return fmt.Sprintf("(\n%s\n)", filter)
+}
 
-func (c *Client) ListProjects(ctx context.Context, query string) ([]string, error) {
-	filter := ""
-	if query != "" {
-		filter = fmt.Sprintf("id:*%s* OR name:*%s*", query, query)
+// ListProjects returns a page of the active projects matching the query.
+// The page size defaults to DefaultProjectPageSize and is capped at
+// MaxProjectPageSize.
+func (c *Client) ListProjects(ctx context.Context, query ProjectQuery) (*ProjectPage, error) {
+	filter, err := query.SearchFilter()
+	if err != nil {
+		return nil, err
+	}
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultProjectPageSize
+	}
+	if pageSize > MaxProjectPageSize {
+		pageSize = MaxProjectPageSize
 	}
 
-	projectIDs := []string{}
-	req := &resourcemanagerpb.SearchProjectsRequest{
-		Query:    filter,
-		PageSize: maxProjects,
+	it := c.rClient.SearchProjects(ctx, &resourcemanagerpb.SearchProjectsRequest{
+		Query: filter,
+	})
+	var projects []*resourcemanagerpb.Project
+	nextPageToken, err := iterator.NewPager(it, int(pageSize), query.PageToken).NextPage(&projects)
+	if err != nil {
+		return nil, err
 	}
-	it := c.rClient.SearchProjects(ctx, req)
-	for {
-		project, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		if project.State != resourcemanagerpb.Project_ACTIVE {
-			continue
-		}
-		projectIDs = append(projectIDs, project.ProjectId)
-		if len(projectIDs) >= maxProjects {
-			break
-		}
+
+	page := &ProjectPage{
+		Projects:      make([]Project, 0, len(projects)),
+		NextPageToken: nextPageToken,
+	}
+	for _, project := range projects {
+		page.Projects = append(page.Projects, projectFromProto(project))
 	}
-	return projectIDs, nil
+	return page, nil
 }
 
-// ListProjectBucketsViews returns all views of a log bucket
-func (c *Client) ListProjectBucketViews(ctx context.Context, projectId string, bucketId string) ([]string, error) {
-	views := []string{""}
+// ListProjectBucketViews returns all views of a log bucket
+func (c *Client) ListProjectBucketViews(ctx context.Context, projectId string, bucketId string) ([]LogView, error) {
+	views := []LogView{}
 
 	req := &loggingpb.ListViewsRequest{
 		// See https://pkg.go.dev/cloud.google.com/go/logging/apiv2/loggingpb#ListViewsRequest
@@ -263,18 +348,16 @@ func (c *Client) ListProjectBucketViews(ctx context.Context, projectId string, b
 		if err != nil {
 			return nil, err
 		}
-		// See response format: https://cloud.google.com/logging/docs/reference/v2/rest/v2/billingAccounts.locations.buckets.views#LogView
-		view := strings.Split(resp.Name, "/")
-		// Append `my-view` for `projects/my-project/locations/global/buckets/my-bucket/views/my-view`
-		views = append(views, view[len(view)-1])
+		views = append(views, logViewFromProto(resp))
 	}
 
 	return views, nil
 }
 
-// ListProjectBuckets returns all log buckets of a project
-func (c *Client) ListProjectBuckets(ctx context.Context, projectId string) ([]string, error) {
-	buckets := []string{""}
+// ListProjectBuckets returns all log buckets of a project, in all locations,
+// along with the BigQuery datasets linked to those with analytics enabled
+func (c *Client) ListProjectBuckets(ctx context.Context, projectId string) ([]LogBucket, error) {
+	buckets := []LogBucket{}
 
 	req := &loggingpb.ListBucketsRequest{
 		// Request struct fields. Using '-' to get the full list
@@ -290,15 +373,113 @@ func (c *Client) ListProjectBuckets(ctx context.Context, projectId string) ([]st
 		if err != nil {
 			return nil, err
 		}
-		// See response format: https://cloud.google.com/logging/docs/reference/v2/rest/v2/billingAccounts.locations.buckets#LogBucket
-		bucket := strings.Split(resp.Name, "/")
-		// Get `global/buckets/my-bucket` for `projects/my-project/locations/global/buckets/my-bucket`
-		buckets = append(buckets, strings.Join(bucket[3:], "/"))
+		bucket := logBucketFromProto(resp)
+		if bucket.AnalyticsEnabled {
+			bucket.LinkedDatasets = c.listLinkedDatasets(ctx, resp.GetName())
+		}
+		buckets = append(buckets, bucket)
 	}
 
 	return buckets, nil
 }
 
+// listLinkedDatasets returns the BigQuery datasets linked to a log bucket.
+// Links are only informative, so failing to list them, such as without the
+// logging.links.list permission, isn't an error.
+func (c *Client) listLinkedDatasets(ctx context.Context, bucketName string) []string {
+	datasets := []string{}
+	it := c.configClient.ListLinks(ctx, &loggingpb.ListLinksRequest{Parent: bucketName})
+	for {
+		link, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.DefaultLogger.Debug("failed listing linked datasets", "bucket", bucketName, "error", err)
+			break
+		}
+		if dataset := link.GetBigqueryDataset().GetDatasetId(); dataset != "" {
+			datasets = append(datasets, dataset)
+		}
+	}
+	return datasets
+}
+
+// ListLogNames returns the names of the logs with entries in a project, or in
+// a view of one of its log buckets if bucketId is set. Names are full resource
+// names, e.g. `projects/my-project/logs/cloudaudit.googleapis.com%2Factivity`,
+// as expected by `logName` filters.
+func (c *Client) ListLogNames(ctx context.Context, projectId string, bucketId string, viewId string) ([]string, error) {
+	req := &loggingpb.ListLogsRequest{
+		// See https://pkg.go.dev/cloud.google.com/go/logging/apiv2/loggingpb#ListLogsRequest
+		Parent: legacyProjectResourceName(projectId),
+	}
+	if bucketId != "" {
+		req.ResourceNames = []string{projectResourceName(projectId, bucketId, viewId)}
+	}
+
+	logNames := []string{}
+	it := c.lClient.ListLogs(ctx, req)
+	for {
+		logName, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		logNames = append(logNames, logName)
+	}
+	sort.Strings(logNames)
+	return logNames, nil
+}
+
+// ListMonitoredResourceTypes returns the types of all monitored resources,
+// e.g. `gce_instance`
+func (c *Client) ListMonitoredResourceTypes(ctx context.Context) ([]string, error) {
+	types := []string{}
+	it := c.lClient.ListMonitoredResourceDescriptors(ctx, &loggingpb.ListMonitoredResourceDescriptorsRequest{})
+	for {
+		descriptor, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, descriptor.GetType())
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// ListLogScopes returns all log scopes of a project, which are all in the
+// global location
+func (c *Client) ListLogScopes(ctx context.Context, projectId string) ([]LogScope, error) {
+	scopes := []LogScope{}
+	err := c.restClient.Projects.Locations.LogScopes.List(fmt.Sprintf("projects/%s/locations/global", projectId)).
+		Pages(ctx, func(resp *loggingrest.ListLogScopesResponse) error {
+			for _, scope := range resp.LogScopes {
+				scopes = append(scopes, logScopeFromREST(scope))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// GetLogScope returns a log scope of a project by ID or resource name
+func (c *Client) GetLogScope(ctx context.Context, projectId string, scopeId string) (*LogScope, error) {
+	scope, err := c.restClient.Projects.Locations.LogScopes.Get(LogScopeName(projectId, scopeId)).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	s := logScopeFromREST(scope)
+	return &s, nil
+}
+
 // TestConnection queries for any log from the given project
 func (c *Client) TestConnection(ctx context.Context, projectID string) error {
 	start := time.Now()
@@ -321,7 +502,7 @@ func (c *Client) TestConnection(ctx context.Context, projectID string) error {
 
 	entry, err := it.Next()
 	if err == iterator.Done {
-		return errors.New("no entries")
+		return ErrNoEntries
 	}
 	if err == context.DeadlineExceeded {
 		return errors.New("list entries: timeout")
@@ -330,29 +511,71 @@ func (c *Client) TestConnection(ctx context.Context, projectID string) error {
 		return fmt.Errorf("list entries: %w", err)
 	}
 	if entry == nil {
-		return errors.New("no entries")
+		return ErrNoEntries
 	}
 
 	return nil
 }
 
-// ListLogs retrieves all logs matching some query filter up to the given limit
-func (c *Client) ListLogs(ctx context.Context, q *Query) ([]*loggingpb.LogEntry, error) {
-	// Never exceed the maximum page size
-	pageSize := int32(math.Min(float64(q.Limit), 1000))
+// TestToken acquires an access token with the client's credentials, to tell
+// authentication failures from missing permissions
+func (c *Client) TestToken(ctx context.Context) error {
+	creds, err := transport.Creds(ctx, append(c.opts, option.WithScopes(readOnlyScope))...)
+	if err != nil {
+		return fmt.Errorf("find credentials: %w", err)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return fmt.Errorf("get token: %w", err)
+	}
+	return nil
+}
 
-	resourceName := []string{}
-	if q.BucketId == "" {
-		resourceName = append(resourceName, legacyProjectResourceName(q.ProjectID))
-	} else {
-		resourceName = append(resourceName, projectResourceName(q.ProjectID, q.BucketId, q.ViewId))
+// TestPermissions returns which of the permissions the client's credentials
+// have on the given project, as reported by Resource Manager
+func (c *Client) TestPermissions(ctx context.Context, projectID string, permissions []string) ([]string, error) {
+	resp, err := c.rClient.TestIamPermissions(ctx, &iampb.TestIamPermissionsRequest{
+		Resource:    legacyProjectResourceName(projectID),
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp.GetPermissions(), nil
+}
 
-	req := loggingpb.ListLogEntriesRequest{
-		ResourceNames: resourceName,
+// listEntriesRequest returns the ListLogEntries request of a query, in pages
+// of up to MaxPageSize entries
+func listEntriesRequest(q *Query) *loggingpb.ListLogEntriesRequest {
+	pageSize := q.Limit
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	orderBy := "timestamp desc"
+	if q.Order == OrderAscending {
+		orderBy = "timestamp asc"
+	}
+
+	return &loggingpb.ListLogEntriesRequest{
+		ResourceNames: q.resourceNames(),
 		Filter:        q.String(),
-		OrderBy:       "timestamp desc",
-		PageSize:      pageSize,
+		OrderBy:       orderBy,
+		PageSize:      int32(pageSize),
+	}
+}
+
+// ListLogs retrieves all logs matching some query filter up to the given limit,
+// sorted by timestamp in the query's order. Limits over MaxPageSize are read
+// over several pages, and limits over parallelFetchThreshold over slices of
+// the time range read in parallel.
+func (c *Client) ListLogs(ctx context.Context, q *Query) ([]*loggingpb.LogEntry, error) {
+	entries := []*loggingpb.LogEntry{}
+	ok, err := c.listSlices(ctx, q, func(page []*loggingpb.LogEntry) error {
+		entries = append(entries, page...)
+		return nil
+	})
+	if ok {
+		return entries, err
 	}
 
 	start := time.Now()
@@ -360,32 +583,137 @@ func (c *Client) ListLogs(ctx context.Context, q *Query) ([]*loggingpb.LogEntry,
 		log.DefaultLogger.Debug("Finished listing logs", "duration", time.Since(start).String())
 	}()
 
-	it := c.lClient.ListLogEntries(ctx, &req)
+	req := listEntriesRequest(q)
+	it := c.lClient.ListLogEntries(ctx, req)
 	if it == nil {
 		return nil, errors.New("nil response")
 	}
 
-	var i int64
-	entries := []*loggingpb.LogEntry{}
-	for {
-		resp, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
+	pager := iterator.NewPager(it, int(req.PageSize), "")
+	for int64(len(entries)) < q.Limit {
+		page := []*loggingpb.LogEntry{}
+		nextPageToken, err := c.nextPage(ctx, pager, &page)
 		if err != nil {
 			log.DefaultLogger.Error("error getting page", "error", err)
 			break
 		}
-
-		entries = append(entries, resp)
-		i++
-		if i >= q.Limit {
+		entries = append(entries, page...)
+		if nextPageToken == "" {
 			break
 		}
 	}
+	if int64(len(entries)) > q.Limit {
+		entries = entries[:q.Limit]
+	}
 	return entries, nil
 }
 
+// ListLogPages retrieves logs matching some query filter up to the given
+// limit, sorted by timestamp in the query's order, passing each page of up to
+// MaxPageSize entries to fn as it's received. Unlike ListLogs, it fails on
+// errors getting pages, as the entries already passed to fn can't be taken
+// back. Limits over parallelFetchThreshold are read over slices of the time
+// range in parallel, as by ListLogs, and the pages of each slice are passed to
+// fn in order once the slices before it are done.
+func (c *Client) ListLogPages(ctx context.Context, q *Query, fn func([]*loggingpb.LogEntry) error) error {
+	if ok, err := c.listSlices(ctx, q, fn); ok {
+		return err
+	}
+
+	req := listEntriesRequest(q)
+	it := c.lClient.ListLogEntries(ctx, req)
+	if it == nil {
+		return errors.New("nil response")
+	}
+
+	pager := iterator.NewPager(it, int(req.PageSize), "")
+	remaining := q.Limit
+	for remaining > 0 {
+		page := []*loggingpb.LogEntry{}
+		nextPageToken, err := c.nextPage(ctx, pager, &page)
+		if err != nil {
+			return err
+		}
+		if int64(len(page)) > remaining {
+			page = page[:remaining]
+		}
+		remaining -= int64(len(page))
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if nextPageToken == "" {
+			break
+		}
+	}
+	return nil
+}
+
+// nextPage gets the next page of entries of a pager, once the page limiter
+// lets the request go out
+func (c *Client) nextPage(ctx context.Context, pager *iterator.Pager, page *[]*loggingpb.LogEntry) (string, error) {
+	if err := c.pageLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	return pager.NextPage(page)
+}
+
+// listSlices reads a query over slices of its time range in parallel if its
+// limit is over parallelFetchThreshold and its time range can be split,
+// passing the entries to fn page by page. It reports whether it did.
+func (c *Client) listSlices(ctx context.Context, q *Query, fn func([]*loggingpb.LogEntry) error) (bool, error) {
+	if q.Limit <= parallelFetchThreshold {
+		return false, nil
+	}
+	slices := timeSlices(q)
+	if slices == nil {
+		return false, nil
+	}
+
+	start := time.Now()
+	defer func() {
+		log.DefaultLogger.Debug("Finished listing logs in parallel", "slices", len(slices), "duration", time.Since(start).String())
+	}()
+	return true, fetchSlices(ctx, slices, q.Limit, c.sliceReader, fn)
+}
+
+// sliceReader returns a reader of the entries of a slice of a query's time
+// range. Entries of a page past those asked for are kept for the next call.
+func (c *Client) sliceReader(q *Query) sliceReader {
+	req := listEntriesRequest(q)
+	var pager *iterator.Pager
+	var buffered []*loggingpb.LogEntry
+	more := true
+
+	return func(ctx context.Context, n int64) ([]*loggingpb.LogEntry, bool, error) {
+		if pager == nil {
+			it := c.lClient.ListLogEntries(ctx, req)
+			if it == nil {
+				return nil, false, errors.New("nil response")
+			}
+			pager = iterator.NewPager(it, int(req.PageSize), "")
+		}
+
+		entries := buffered
+		buffered = nil
+		for int64(len(entries)) < n && more {
+			page := []*loggingpb.LogEntry{}
+			nextPageToken, err := c.nextPage(ctx, pager, &page)
+			if err != nil {
+				return entries, false, err
+			}
+			entries = append(entries, page...)
+			more = nextPageToken != ""
+		}
+		if int64(len(entries)) > n {
+			buffered = append(buffered, entries[n:]...)
+			entries = entries[:n]
+		}
+		return entries, more || len(buffered) > 0, nil
+	}
+}
+
 func legacyProjectResourceName(projectID string) string {
 	return fmt.Sprintf("projects/%s", projectID)
 }
@@ -17,7 +17,10 @@ package cloudlogging
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/logging/apiv2/loggingpb"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
@@ -25,6 +28,7 @@ import (
 	rlpb "google.golang.org/genproto/googleapis/appengine/logging/v1"
 	alpb "google.golang.org/genproto/googleapis/cloud/audit"
 	ltype "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -62,7 +66,7 @@ func GetLogEntryMessage(entry *loggingpb.LogEntry) (string, error) {
 	}
 }
 
-// GetLogLabels flattens a log entry's labels + resource labels into a map
+// GetLogLabels flattens a log entry's labels + resource labels and metadata into a map
 func GetLogLabels(entry *loggingpb.LogEntry) data.Labels {
 	labels := make(data.Labels)
 	for k, v := range entry.GetLabels() {
@@ -98,7 +102,7 @@ func GetLogLabels(entry *loggingpb.LogEntry) data.Labels {
 			if err := t.ProtoPayload.UnmarshalTo(&a); err != nil {
 				log.DefaultLogger.Error("Could not get AuditLog payload out of LogEntry", "error", err)
 			} else {
-				byteArr, _ := json.Marshal(a)
+				byteArr, _ := json.Marshal(&a)
 				var inInterface map[string]*structpb.Value
 				json.Unmarshal(byteArr, &inInterface)
 				for k, v := range inInterface {
@@ -110,7 +114,7 @@ func GetLogLabels(entry *loggingpb.LogEntry) data.Labels {
 			if err := t.ProtoPayload.UnmarshalTo(&r); err != nil {
 				log.DefaultLogger.Error("Could not get RequestLog payload out of LogEntry", "error", err)
 			} else {
-				byteArr, _ := json.Marshal(r)
+				byteArr, _ := json.Marshal(&r)
 				var inInterface map[string]*structpb.Value
 				json.Unmarshal(byteArr, &inInterface)
 				for k, v := range inInterface {
@@ -152,10 +156,178 @@ func GetLogLabels(entry *loggingpb.LogEntry) data.Labels {
 	if spanId != "" {
 		labels["spanId"] = entry.GetSpanId()
 	}
+	if entry.GetTraceSampled() {
+		labels["traceSampled"] = "true"
+	}
+
+	if logName := entry.GetLogName(); logName != "" {
+		labels["logName"] = logName
+	}
+	if receiveTimestamp := entry.GetReceiveTimestamp(); receiveTimestamp != nil {
+		labels["receiveTimestamp"] = receiveTimestamp.AsTime().Format(time.RFC3339Nano)
+	}
+
+	// Operation data lets users correlate the entries of a long-running operation
+	if operation := entry.GetOperation(); operation != nil {
+		if id := operation.GetId(); id != "" {
+			labels["operation.id"] = id
+		}
+		if producer := operation.GetProducer(); producer != "" {
+			labels["operation.producer"] = producer
+		}
+		if operation.GetFirst() {
+			labels["operation.first"] = "true"
+		}
+		if operation.GetLast() {
+			labels["operation.last"] = "true"
+		}
+	}
+
+	if sourceLocation := entry.GetSourceLocation(); sourceLocation != nil {
+		if file := sourceLocation.GetFile(); file != "" {
+			labels["sourceLocation.file"] = file
+		}
+		if line := sourceLocation.GetLine(); line != 0 {
+			labels["sourceLocation.line"] = strconv.FormatInt(line, 10)
+		}
+		if function := sourceLocation.GetFunction(); function != "" {
+			labels["sourceLocation.function"] = function
+		}
+	}
+
+	if split := entry.GetSplit(); split != nil {
+		labels["split.uid"] = split.GetUid()
+		labels["split.index"] = strconv.FormatInt(int64(split.GetIndex()), 10)
+		labels["split.totalSplits"] = strconv.FormatInt(int64(split.GetTotalSplits()), 10)
+	}
 
 	return labels
 }
 
+// MergeSplitEntries reassembles log entries that Cloud Logging split into
+// several parts (see LogSplit) back into a single entry. Parts are joined in
+// split index order and the merged entry takes the place of the first part
+// found in entries; entries that were not split are returned unchanged.
+func MergeSplitEntries(entries []*loggingpb.LogEntry) []*loggingpb.LogEntry {
+	parts := make(map[string][]*loggingpb.LogEntry)
+	for _, entry := range entries {
+		if uid := entry.GetSplit().GetUid(); uid != "" {
+			parts[uid] = append(parts[uid], entry)
+		}
+	}
+	if len(parts) == 0 {
+		return entries
+	}
+
+	merged := make([]*loggingpb.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		uid := entry.GetSplit().GetUid()
+		if uid == "" {
+			merged = append(merged, entry)
+			continue
+		}
+		group, ok := parts[uid]
+		if !ok {
+			// Already merged into an earlier entry
+			continue
+		}
+		delete(parts, uid)
+		merged = append(merged, mergeSplitParts(group))
+	}
+	return merged
+}
+
+// DedupEntries removes the entries seen more than once, such as when a query
+// spans a project and a bucket its logs are routed to, or two views of the
+// same bucket. Entries are the same if they have the same log name, insert ID
+// and timestamp; the first one found is kept. It returns the remaining
+// entries and how many were removed.
+func DedupEntries(entries []*loggingpb.LogEntry) ([]*loggingpb.LogEntry, int) {
+	return NewDeduplicator().Dedup(entries)
+}
+
+// entryKey identifies a log entry for deduplication
+type entryKey struct {
+	logName  string
+	insertId string
+	seconds  int64
+	nanos    int32
+}
+
+// Deduplicator removes entries seen more than once across calls, for
+// entries read page by page
+type Deduplicator struct {
+	seen map[entryKey]bool
+}
+
+// NewDeduplicator creates a Deduplicator that hasn't seen any entry yet
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{seen: map[entryKey]bool{}}
+}
+
+// Dedup removes the entries seen in this or earlier calls, as DedupEntries
+// does. It returns the remaining entries and how many were removed.
+func (d *Deduplicator) Dedup(entries []*loggingpb.LogEntry) ([]*loggingpb.LogEntry, int) {
+	unique := make([]*loggingpb.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		key := entryKey{
+			logName:  entry.GetLogName(),
+			insertId: entry.GetInsertId(),
+			seconds:  entry.GetTimestamp().GetSeconds(),
+			nanos:    entry.GetTimestamp().GetNanos(),
+		}
+		if d.seen[key] {
+			continue
+		}
+		d.seen[key] = true
+		unique = append(unique, entry)
+	}
+	return unique, len(entries) - len(unique)
+}
+
+// mergeSplitParts joins the payloads of the parts of a single split entry.
+// Text payloads are concatenated; for JSON payloads the `message` fields are
+// concatenated and the remaining fields are combined.
+func mergeSplitParts(group []*loggingpb.LogEntry) *loggingpb.LogEntry {
+	if len(group) == 1 {
+		return group[0]
+	}
+	sort.SliceStable(group, func(i, j int) bool {
+		return group[i].GetSplit().GetIndex() < group[j].GetSplit().GetIndex()
+	})
+
+	merged := proto.Clone(group[0]).(*loggingpb.LogEntry)
+	switch merged.GetPayload().(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		var text strings.Builder
+		for _, part := range group {
+			text.WriteString(part.GetTextPayload())
+		}
+		merged.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: text.String()}
+	case *loggingpb.LogEntry_JsonPayload:
+		fields := make(map[string]*structpb.Value)
+		var message strings.Builder
+		hasMessage := false
+		for _, part := range group {
+			for k, v := range part.GetJsonPayload().GetFields() {
+				if k == "message" {
+					if _, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+						message.WriteString(v.GetStringValue())
+						hasMessage = true
+						continue
+					}
+				}
+				fields[k] = v
+			}
+		}
+		if hasMessage {
+			fields["message"] = structpb.NewStringValue(message.String())
+		}
+		merged.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: &structpb.Struct{Fields: fields}}
+	}
+	return merged
+}
+
 // GetLogLevel maps the string value of a LogSeverity to one supported by Grafana
 func GetLogLevel(severity ltype.LogSeverity) string {
 	switch severity {
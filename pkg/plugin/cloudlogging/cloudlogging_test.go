@@ -17,6 +17,7 @@ package cloudlogging_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/logging/apiv2/loggingpb"
 	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
@@ -26,6 +27,7 @@ import (
 	ltype "google.golang.org/genproto/googleapis/logging/type"
 	anypb "google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestGetLogEntryMessage(t *testing.T) {
@@ -376,6 +378,49 @@ func TestGetLogLabels(t *testing.T) {
 				"spanId":  "000000000000004a",
 			},
 		},
+		{
+			name: "Operation, source location and log metadata",
+			entry: &loggingpb.LogEntry{
+				InsertId:         "insert-id10",
+				LogName:          "projects/my-project/logs/stdout",
+				ReceiveTimestamp: timestamppb.New(time.Date(2024, 3, 1, 12, 30, 0, 500000000, time.UTC)),
+				Trace:            "projects/my-project/traces/06796866738c859f2f19b7cfb3214824",
+				TraceSampled:     true,
+				Operation: &loggingpb.LogEntryOperation{
+					Id:       "op-123",
+					Producer: "github.com/my/app",
+					First:    true,
+				},
+				SourceLocation: &loggingpb.LogEntrySourceLocation{
+					File:     "main.go",
+					Line:     42,
+					Function: "main.run",
+				},
+				Split: &loggingpb.LogSplit{
+					Uid:         "split-uid",
+					Index:       1,
+					TotalSplits: 3,
+				},
+			},
+			expected: data.Labels{
+				"id":                      "insert-id10",
+				"level":                   "info",
+				"logName":                 "projects/my-project/logs/stdout",
+				"receiveTimestamp":        "2024-03-01T12:30:00.5Z",
+				"trace":                   "projects/my-project/traces/06796866738c859f2f19b7cfb3214824",
+				"traceId":                 "06796866738c859f2f19b7cfb3214824",
+				"traceSampled":            "true",
+				"operation.id":            "op-123",
+				"operation.producer":      "github.com/my/app",
+				"operation.first":         "true",
+				"sourceLocation.file":     "main.go",
+				"sourceLocation.line":     "42",
+				"sourceLocation.function": "main.run",
+				"split.uid":               "split-uid",
+				"split.index":             "1",
+				"split.totalSplits":       "3",
+			},
+		},
 		{
 			name: "JSON payload with various field types",
 			entry: &loggingpb.LogEntry{
@@ -449,3 +494,85 @@ func TestGetLogLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestDedupEntries(t *testing.T) {
+	t.Parallel()
+	now := timestamppb.Now()
+	later := timestamppb.New(now.AsTime().Add(time.Second))
+	entries := []*loggingpb.LogEntry{
+		{LogName: "projects/p/logs/app", InsertId: "a", Timestamp: now},
+		{LogName: "projects/p/logs/app", InsertId: "b", Timestamp: now},
+		{LogName: "projects/p/logs/app", InsertId: "a", Timestamp: now},
+		// Same insert ID in another log or at another time
+		{LogName: "projects/p/logs/other", InsertId: "a", Timestamp: now},
+		{LogName: "projects/p/logs/app", InsertId: "a", Timestamp: later},
+		{LogName: "projects/p/logs/app", InsertId: "b", Timestamp: now},
+	}
+
+	unique, removed := cloudlogging.DedupEntries(entries)
+	require.Equal(t, 2, removed)
+	require.Equal(t, []*loggingpb.LogEntry{entries[0], entries[1], entries[3], entries[4]}, unique)
+
+	unique, removed = cloudlogging.DedupEntries(nil)
+	require.Equal(t, 0, removed)
+	require.Empty(t, unique)
+
+	// Entries of earlier pages are remembered
+	d := cloudlogging.NewDeduplicator()
+	_, removed = d.Dedup(entries[:2])
+	require.Equal(t, 0, removed)
+	unique, removed = d.Dedup(entries[2:])
+	require.Equal(t, 2, removed)
+	require.Equal(t, []*loggingpb.LogEntry{entries[3], entries[4]}, unique)
+}
+
+func TestMergeSplitEntries(t *testing.T) {
+	t.Parallel()
+	textPart := func(id string, index int32, text string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId: id,
+			Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: text},
+			Split:    &loggingpb.LogSplit{Uid: "text-split", Index: index, TotalSplits: 3},
+		}
+	}
+	jsonPart := func(id string, index int32, fields map[string]*structpb.Value) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId: id,
+			Payload:  &loggingpb.LogEntry_JsonPayload{JsonPayload: &structpb.Struct{Fields: fields}},
+			Split:    &loggingpb.LogSplit{Uid: "json-split", Index: index, TotalSplits: 2},
+		}
+	}
+
+	entries := []*loggingpb.LogEntry{
+		{InsertId: "single", Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "not split"}},
+		textPart("text-1", 1, "second "),
+		jsonPart("json-0", 0, map[string]*structpb.Value{
+			"message": structpb.NewStringValue("hello "),
+			"pid":     structpb.NewNumberValue(7),
+		}),
+		textPart("text-0", 0, "first "),
+		jsonPart("json-1", 1, map[string]*structpb.Value{
+			"message": structpb.NewStringValue("world"),
+		}),
+		textPart("text-2", 2, "third"),
+	}
+
+	merged := cloudlogging.MergeSplitEntries(entries)
+	require.Len(t, merged, 3)
+
+	require.Equal(t, "single", merged[0].GetInsertId())
+
+	// The merged entry takes the place of the first part encountered, with
+	// the metadata of the part at index 0
+	require.Equal(t, "text-0", merged[1].GetInsertId())
+	require.Equal(t, "first second third", merged[1].GetTextPayload())
+
+	require.Equal(t, "json-0", merged[2].GetInsertId())
+	message, err := cloudlogging.GetLogEntryMessage(merged[2])
+	require.NoError(t, err)
+	require.Equal(t, "hello world", message)
+	require.Equal(t, float64(7), merged[2].GetJsonPayload().GetFields()["pid"].GetNumberValue())
+
+	// The input entries must not be modified
+	require.Equal(t, "first ", entries[3].GetTextPayload())
+}
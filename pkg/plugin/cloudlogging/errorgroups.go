@@ -0,0 +1,358 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// Languages of the stack traces recognized by DetectStackTrace
+const (
+	LanguageJava   = "java"
+	LanguagePython = "python"
+	LanguageGo     = "go"
+	LanguageNode   = "nodejs"
+)
+
+// maxFingerprintFrames is how many of the top frames of a stack trace are used
+// to fingerprint it. Deeper frames are mostly framework code shared by
+// unrelated errors, or vary with the call path into the faulty code.
+const maxFingerprintFrames = 10
+
+var (
+	// at com.example.Foo.bar(Foo.java:42)
+	javaFramePattern = regexp.MustCompile(`^\s*at\s+([\w$.<>/]+)\(.*\)\s*$`)
+	// ... 12 more, Caused by: ..., Suppressed: ...
+	javaContinuationPattern = regexp.MustCompile(`^\s*(\.\.\. \d+ (more|common frames omitted)|Caused by:|Suppressed:)`)
+	// at Object.<anonymous> (/app/index.js:10:15) or at /app/index.js:10:15
+	nodeFramePattern = regexp.MustCompile(`^\s*at\s+(?:(.+?)\s+\()?(.+?):\d+:\d+\)?\s*$`)
+	// File "/app/main.py", line 10, in main
+	pythonFramePattern = regexp.MustCompile(`^\s*File "(.+)", line \d+, in (.+)$`)
+	// goroutine 1 [running]:
+	goroutinePattern = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	// main.(*Server).handle(0xc000123456, {0x0, 0x0})
+	goFuncPattern = regexp.MustCompile(`^(\S+)\(.*\)$`)
+	// 	/app/main.go:10 +0x1d
+	goFilePattern = regexp.MustCompile(`^\s+\S+\.go:\d+`)
+
+	// Generated names such as Java lambdas (lambda$main$0), anonymous classes
+	// (Foo$1) and Go closures (main.func1.2) differ between builds
+	generatedNamePattern = regexp.MustCompile(`\$\d+|\.func\d+(\.\d+)*`)
+	// Exception types are the leading identifier of the exception line,
+	// e.g. `java.lang.IllegalStateException` or `ValueError`
+	exceptionTypePattern = regexp.MustCompile(`^[\w$.]+`)
+)
+
+// StackTrace is a stack trace detected in a log message
+type StackTrace struct {
+	// Language is the language the stack trace was produced by
+	Language string
+	// Exception is the line describing the error, such as `ValueError: bad input`
+	Exception string
+	// Frames are the normalized stack frames, innermost first
+	Frames []string
+}
+
+// ExceptionType returns the type of the exception, without its message
+func (s *StackTrace) ExceptionType() string {
+	if s.Language == LanguageGo {
+		// `panic: runtime error: index out of range [5] with length 3`
+		parts := strings.SplitN(s.Exception, ":", 3)
+		if len(parts) == 3 {
+			return strings.TrimSpace(parts[0] + ":" + parts[1])
+		}
+		return strings.TrimSpace(parts[0])
+	}
+	return exceptionTypePattern.FindString(strings.TrimSpace(s.Exception))
+}
+
+// Fingerprint identifies the stack trace by its language, exception type and
+// top frames, so that repeated occurrences of the same error share it even if
+// their messages, line numbers or memory addresses differ
+func (s *StackTrace) Fingerprint() string {
+	frames := s.Frames
+	if len(frames) > maxFingerprintFrames {
+		frames = frames[:maxFingerprintFrames]
+	}
+	h := sha256.New()
+	h.Write([]byte(s.Language))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(s.ExceptionType()))
+	for _, frame := range frames {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(frame))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// DetectStackTrace looks for a Java, Python, Go or Node.js stack trace in
+// message and returns it with its frames normalized
+func DetectStackTrace(message string) (*StackTrace, bool) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	for _, detect := range []func([]string) *StackTrace{
+		detectPythonStackTrace,
+		detectGoStackTrace,
+		detectNodeStackTrace,
+		detectJavaStackTrace,
+	} {
+		if trace := detect(lines); trace != nil && len(trace.Frames) > 0 {
+			return trace, true
+		}
+	}
+	return nil, false
+}
+
+func detectJavaStackTrace(lines []string) *StackTrace {
+	var trace *StackTrace
+	for i, line := range lines {
+		match := javaFramePattern.FindStringSubmatch(line)
+		if match == nil {
+			if trace != nil && !javaContinuationPattern.MatchString(line) && strings.TrimSpace(line) != "" {
+				break
+			}
+			continue
+		}
+		if trace == nil {
+			trace = &StackTrace{
+				Language:  LanguageJava,
+				Exception: precedingLine(lines, i),
+			}
+		}
+		trace.Frames = append(trace.Frames, normalizeFunction(match[1]))
+	}
+	return trace
+}
+
+func detectNodeStackTrace(lines []string) *StackTrace {
+	var trace *StackTrace
+	for i, line := range lines {
+		match := nodeFramePattern.FindStringSubmatch(line)
+		if match == nil || javaFramePattern.MatchString(line) {
+			if trace != nil {
+				break
+			}
+			continue
+		}
+		if trace == nil {
+			trace = &StackTrace{
+				Language:  LanguageNode,
+				Exception: precedingLine(lines, i),
+			}
+		}
+		// Anonymous functions only have a location; use the file name
+		// without the line and column
+		frame := match[1]
+		if frame == "" {
+			frame = match[2]
+		}
+		trace.Frames = append(trace.Frames, normalizeFunction(frame))
+	}
+	return trace
+}
+
+func detectPythonStackTrace(lines []string) *StackTrace {
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "Traceback (most recent call last)") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	trace := &StackTrace{Language: LanguagePython}
+	var frames []string
+	for _, line := range lines[start+1:] {
+		if match := pythonFramePattern.FindStringSubmatch(line); match != nil {
+			frames = append(frames, normalizeFunction(match[1]+":"+match[2]))
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.TrimSpace(line) == "" {
+			// Source code lines printed below each frame
+			continue
+		}
+		trace.Exception = strings.TrimSpace(line)
+		break
+	}
+	// Python prints the innermost frame last
+	for i := len(frames) - 1; i >= 0; i-- {
+		trace.Frames = append(trace.Frames, frames[i])
+	}
+	return trace
+}
+
+func detectGoStackTrace(lines []string) *StackTrace {
+	start := -1
+	for i, line := range lines {
+		if goroutinePattern.MatchString(strings.TrimSpace(line)) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	trace := &StackTrace{Language: LanguageGo}
+	for _, line := range lines[:start] {
+		if line = strings.TrimSpace(line); line != "" {
+			trace.Exception = line
+			break
+		}
+	}
+	rest := lines[start+1:]
+	for i := 0; i+1 < len(rest); i++ {
+		match := goFuncPattern.FindStringSubmatch(rest[i])
+		if match == nil || !goFilePattern.MatchString(rest[i+1]) {
+			if strings.TrimSpace(rest[i]) == "" {
+				// Only the first goroutine is the one that failed
+				break
+			}
+			continue
+		}
+		trace.Frames = append(trace.Frames, normalizeFunction(match[1]))
+		i++
+	}
+	return trace
+}
+
+// precedingLine returns the closest non-empty line before lines[i], which
+// holds the exception of Java and Node.js stack traces
+func precedingLine(lines []string, i int) string {
+	for j := i - 1; j >= 0; j-- {
+		if line := strings.TrimSpace(lines[j]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func normalizeFunction(name string) string {
+	return generatedNamePattern.ReplaceAllString(strings.TrimSpace(name), "")
+}
+
+// ErrorGroup is a set of log entries whose stack traces share a fingerprint
+type ErrorGroup struct {
+	Fingerprint string
+	Language    string
+	// Exception is the error line of the most recent occurrence
+	Exception string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Services are the services or resources the error occurred in
+	Services []string
+	// SampleID is the insert ID of the most recent occurrence
+	SampleID string
+}
+
+// GroupErrors detects stack traces in the given entries and groups them by
+// fingerprint. Groups are sorted by count, most frequent first
+func GroupErrors(entries []*loggingpb.LogEntry) []*ErrorGroup {
+	groups := map[string]*ErrorGroup{}
+	services := map[string]map[string]bool{}
+
+	for _, entry := range entries {
+		trace, ok := entryStackTrace(entry)
+		if !ok {
+			continue
+		}
+		fingerprint := trace.Fingerprint()
+		timestamp := entry.GetTimestamp().AsTime()
+
+		group, ok := groups[fingerprint]
+		if !ok {
+			group = &ErrorGroup{
+				Fingerprint: fingerprint,
+				Language:    trace.Language,
+				FirstSeen:   timestamp,
+			}
+			groups[fingerprint] = group
+			services[fingerprint] = map[string]bool{}
+		}
+		group.Count++
+		if timestamp.Before(group.FirstSeen) {
+			group.FirstSeen = timestamp
+		}
+		if group.SampleID == "" || !timestamp.Before(group.LastSeen) {
+			group.LastSeen = timestamp
+			group.Exception = trace.Exception
+			group.SampleID = entry.GetInsertId()
+		}
+		if service := entryService(entry); service != "" && !services[fingerprint][service] {
+			services[fingerprint][service] = true
+			group.Services = append(group.Services, service)
+		}
+	}
+
+	result := make([]*ErrorGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Strings(group.Services)
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	return result
+}
+
+// entryStackTrace looks for a stack trace in the message of an entry, falling
+// back to the `stack_trace` field of JSON payloads
+func entryStackTrace(entry *loggingpb.LogEntry) (*StackTrace, bool) {
+	if message, err := GetLogEntryMessage(entry); err == nil {
+		if trace, ok := DetectStackTrace(message); ok {
+			return trace, true
+		}
+	}
+	if stackTrace := entry.GetJsonPayload().GetFields()["stack_trace"].GetStringValue(); stackTrace != "" {
+		return DetectStackTrace(stackTrace)
+	}
+	return nil, false
+}
+
+// serviceResourceLabels are the resource labels that name the service that
+// wrote an entry, in order of preference
+var serviceResourceLabels = []string{"service_name", "container_name", "function_name", "module_id", "job_name"}
+
+// entryService returns the service that wrote an entry, preferring the
+// Error Reporting `serviceContext` of JSON payloads
+func entryService(entry *loggingpb.LogEntry) string {
+	serviceContext := entry.GetJsonPayload().GetFields()["serviceContext"].GetStructValue()
+	if service := serviceContext.GetFields()["service"].GetStringValue(); service != "" {
+		return service
+	}
+	resourceLabels := entry.GetResource().GetLabels()
+	for _, label := range serviceResourceLabels {
+		if service := resourceLabels[label]; service != "" {
+			return service
+		}
+	}
+	return entry.GetResource().GetType()
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	javaTrace = `java.lang.IllegalStateException: connection 1234 closed
+	at com.example.db.Pool.acquire(Pool.java:88)
+	at com.example.api.Handler.lambda$handle$0(Handler.java:42)
+	at com.example.api.Server.run(Server.java:10)
+Caused by: java.io.IOException: broken pipe
+	at com.example.db.Conn.write(Conn.java:12)
+	... 3 more`
+	pythonTrace = `Traceback (most recent call last):
+  File "/app/main.py", line 10, in <module>
+    main()
+  File "/app/main.py", line 6, in main
+    raise ValueError("bad input 42")
+ValueError: bad input 42`
+	goTrace = `panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.handle.func1(0xc000012345)
+	/app/main.go:12 +0x1d
+main.main()
+	/app/main.go:20 +0x25
+exit status 2`
+	nodeTrace = `TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/users.js:10:15)
+    at /app/index.js:22:7
+    at processTicksAndRejections (node:internal/process/task_queues:95:5)`
+)
+
+func TestDetectStackTrace(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		message       string
+		language      string
+		exception     string
+		exceptionType string
+		frames        []string
+	}{
+		{
+			name:          "Java",
+			message:       javaTrace,
+			language:      cloudlogging.LanguageJava,
+			exception:     "java.lang.IllegalStateException: connection 1234 closed",
+			exceptionType: "java.lang.IllegalStateException",
+			frames: []string{
+				"com.example.db.Pool.acquire",
+				"com.example.api.Handler.lambda$handle",
+				"com.example.api.Server.run",
+				"com.example.db.Conn.write",
+			},
+		},
+		{
+			name:          "Python",
+			message:       pythonTrace,
+			language:      cloudlogging.LanguagePython,
+			exception:     "ValueError: bad input 42",
+			exceptionType: "ValueError",
+			frames: []string{
+				"/app/main.py:main",
+				"/app/main.py:<module>",
+			},
+		},
+		{
+			name:          "Go",
+			message:       goTrace,
+			language:      cloudlogging.LanguageGo,
+			exception:     "panic: runtime error: index out of range [5] with length 3",
+			exceptionType: "panic: runtime error",
+			frames: []string{
+				"main.handle",
+				"main.main",
+			},
+		},
+		{
+			name:          "Node.js",
+			message:       nodeTrace,
+			language:      cloudlogging.LanguageNode,
+			exception:     "TypeError: Cannot read properties of undefined (reading 'id')",
+			exceptionType: "TypeError",
+			frames: []string{
+				"getUser",
+				"/app/index.js",
+				"processTicksAndRejections",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trace, ok := cloudlogging.DetectStackTrace(tc.message)
+			require.True(t, ok)
+			require.Equal(t, tc.language, trace.Language)
+			require.Equal(t, tc.exception, trace.Exception)
+			require.Equal(t, tc.exceptionType, trace.ExceptionType())
+			require.Equal(t, tc.frames, trace.Frames)
+		})
+	}
+
+	t.Run("No stack trace", func(t *testing.T) {
+		_, ok := cloudlogging.DetectStackTrace("GET /healthz 200 at 12:00:01")
+		require.False(t, ok)
+	})
+}
+
+func TestStackTraceFingerprint(t *testing.T) {
+	t.Parallel()
+	first, ok := cloudlogging.DetectStackTrace(pythonTrace)
+	require.True(t, ok)
+
+	// Different messages and line numbers are the same error
+	second, ok := cloudlogging.DetectStackTrace(`Traceback (most recent call last):
+  File "/app/main.py", line 11, in <module>
+    main()
+  File "/app/main.py", line 7, in main
+    raise ValueError("bad input 7")
+ValueError: bad input 7`)
+	require.True(t, ok)
+	require.Equal(t, first.Fingerprint(), second.Fingerprint())
+
+	// A different exception type is a different error
+	third, ok := cloudlogging.DetectStackTrace(`Traceback (most recent call last):
+  File "/app/main.py", line 10, in <module>
+    main()
+  File "/app/main.py", line 6, in main
+    raise KeyError("id")
+KeyError: 'id'`)
+	require.True(t, ok)
+	require.NotEqual(t, first.Fingerprint(), third.Fingerprint())
+}
+
+func TestGroupErrors(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	textEntry := func(id string, offset time.Duration, container string, text string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId:  id,
+			Timestamp: timestamppb.New(base.Add(offset)),
+			Resource: &monitoredres.MonitoredResource{
+				Type:   "k8s_container",
+				Labels: map[string]string{"container_name": container},
+			},
+			Payload: &loggingpb.LogEntry_TextPayload{TextPayload: text},
+		}
+	}
+
+	entries := []*loggingpb.LogEntry{
+		textEntry("py-1", 3*time.Minute, "api", pythonTrace),
+		textEntry("plain", 2*time.Minute, "api", "request served"),
+		textEntry("py-2", time.Minute, "worker", pythonTrace),
+		{
+			InsertId:  "java-1",
+			Timestamp: timestamppb.New(base),
+			Payload: &loggingpb.LogEntry_JsonPayload{
+				JsonPayload: &structpb.Struct{Fields: map[string]*structpb.Value{
+					"message":     structpb.NewStringValue("request failed"),
+					"stack_trace": structpb.NewStringValue(javaTrace),
+					"serviceContext": structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+						"service": structpb.NewStringValue("billing"),
+					}}),
+				}},
+			},
+		},
+	}
+
+	groups := cloudlogging.GroupErrors(entries)
+	require.Len(t, groups, 2)
+
+	require.Equal(t, cloudlogging.LanguagePython, groups[0].Language)
+	require.Equal(t, int64(2), groups[0].Count)
+	require.Equal(t, base.Add(time.Minute), groups[0].FirstSeen)
+	require.Equal(t, base.Add(3*time.Minute), groups[0].LastSeen)
+	require.Equal(t, []string{"api", "worker"}, groups[0].Services)
+	require.Equal(t, "py-1", groups[0].SampleID)
+
+	require.Equal(t, cloudlogging.LanguageJava, groups[1].Language)
+	require.Equal(t, int64(1), groups[1].Count)
+	require.Equal(t, []string{"billing"}, groups[1].Services)
+	require.Equal(t, "java-1", groups[1].SampleID)
+}
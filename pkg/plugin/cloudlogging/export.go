@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Export formats
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatCSV    = "csv"
+)
+
+// Export fields that aren't flattened by GetLogLabels
+const (
+	exportFieldTimestamp = "timestamp"
+	exportFieldMessage   = "message"
+)
+
+// DefaultExportFields are the CSV columns when no fields are selected
+var DefaultExportFields = []string{exportFieldTimestamp, "severity", "logName", "insertId", exportFieldMessage}
+
+// ExportEncoder encodes log entries for export, as newline delimited JSON or
+// CSV with a header row
+type ExportEncoder struct {
+	format string
+	// fields are the selected fields. NDJSON exports whole entries if empty.
+	fields []string
+	buf    bytes.Buffer
+	csv    *csv.Writer
+	header bool
+}
+
+// NewExportEncoder validates the format and selected fields of an export.
+// Fields are referenced by path as in FieldValues, and can also be
+// `timestamp` or `message`, the text of the entry as shown in Grafana.
+func NewExportEncoder(format string, fields []string) (*ExportEncoder, error) {
+	for _, field := range fields {
+		if field == exportFieldTimestamp || field == exportFieldMessage {
+			continue
+		}
+		if err := ValidateFieldPath(field); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &ExportEncoder{format: format, fields: fields}
+	switch format {
+	case ExportFormatNDJSON:
+	case ExportFormatCSV:
+		if len(e.fields) == 0 {
+			e.fields = DefaultExportFields
+		}
+		e.csv = csv.NewWriter(&e.buf)
+	default:
+		return nil, fmt.Errorf("invalid export format %q: must be %q or %q", format, ExportFormatNDJSON, ExportFormatCSV)
+	}
+	return e, nil
+}
+
+// ContentType is the media type of the export
+func (e *ExportEncoder) ContentType() string {
+	if e.format == ExportFormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Encode returns the encoded entries, preceded by the CSV header on the first call
+func (e *ExportEncoder) Encode(entries []*loggingpb.LogEntry) ([]byte, error) {
+	e.buf.Reset()
+	if e.csv != nil {
+		if !e.header {
+			if err := e.csv.Write(e.fields); err != nil {
+				return nil, err
+			}
+			e.header = true
+		}
+		for _, entry := range entries {
+			if err := e.csv.Write(e.values(entry)); err != nil {
+				return nil, err
+			}
+		}
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, entry := range entries {
+			line, err := e.jsonLine(entry)
+			if err != nil {
+				return nil, err
+			}
+			e.buf.Write(line)
+			e.buf.WriteByte('\n')
+		}
+	}
+	return bytes.Clone(e.buf.Bytes()), nil
+}
+
+// jsonLine is the entry, or an object of its selected fields, as one line of JSON
+func (e *ExportEncoder) jsonLine(entry *loggingpb.LogEntry) ([]byte, error) {
+	if len(e.fields) == 0 {
+		// protojson doesn't break lines unless asked to
+		return protojson.Marshal(entry)
+	}
+	object := map[string]string{}
+	values := e.values(entry)
+	for i, field := range e.fields {
+		if values[i] != "" {
+			object[field] = values[i]
+		}
+	}
+	return json.Marshal(object)
+}
+
+// values are the values of the selected fields of the entry, empty if absent
+func (e *ExportEncoder) values(entry *loggingpb.LogEntry) []string {
+	values := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		switch field {
+		case exportFieldTimestamp:
+			values[i] = entry.GetTimestamp().AsTime().Format(time.RFC3339Nano)
+		case exportFieldMessage:
+			values[i], _ = GetLogEntryMessage(entry)
+		default:
+			values[i], _ = fieldValue(entry, field)
+		}
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestExportEncoder(t *testing.T) {
+	t.Parallel()
+	entries := []*loggingpb.LogEntry{
+		{
+			InsertId:  "a",
+			LogName:   "projects/my-project/logs/app",
+			Severity:  ltype.LogSeverity_ERROR,
+			Timestamp: timestamppb.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)),
+			Labels:    map[string]string{"env": "prod"},
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "line one\nline two"},
+		},
+		{
+			InsertId:  "b",
+			Timestamp: timestamppb.New(time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)),
+		},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+		e, err := cloudlogging.NewExportEncoder(cloudlogging.ExportFormatCSV, nil)
+		require.NoError(t, err)
+		first, err := e.Encode(entries[:1])
+		require.NoError(t, err)
+		second, err := e.Encode(entries[1:])
+		require.NoError(t, err)
+		require.Equal(t, "timestamp,severity,logName,insertId,message\n"+
+			"2024-03-01T12:00:00Z,ERROR,projects/my-project/logs/app,a,\"line one\nline two\"\n", string(first))
+		require.Equal(t, "2024-03-01T12:00:01Z,DEFAULT,,b,\n", string(second))
+	})
+
+	t.Run("ndjson entries", func(t *testing.T) {
+		t.Parallel()
+		e, err := cloudlogging.NewExportEncoder(cloudlogging.ExportFormatNDJSON, nil)
+		require.NoError(t, err)
+		out, err := e.Encode(entries)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		require.Len(t, lines, 2)
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		require.Equal(t, "a", entry["insertId"])
+		require.Equal(t, "line one\nline two", entry["textPayload"])
+	})
+
+	t.Run("ndjson fields", func(t *testing.T) {
+		t.Parallel()
+		e, err := cloudlogging.NewExportEncoder(cloudlogging.ExportFormatNDJSON, []string{"insertId", "labels.env"})
+		require.NoError(t, err)
+		out, err := e.Encode(entries)
+		require.NoError(t, err)
+		require.Equal(t, "{\"insertId\":\"a\",\"labels.env\":\"prod\"}\n{\"insertId\":\"b\"}\n", string(out))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := cloudlogging.NewExportEncoder("xml", nil)
+		require.ErrorContains(t, err, "invalid export format")
+		_, err = cloudlogging.NewExportEncoder(cloudlogging.ExportFormatCSV, []string{"labels.env OR true"})
+		require.ErrorContains(t, err, "invalid field path")
+	})
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// Observed types of field values
+const (
+	FieldTypeString = "string"
+	FieldTypeNumber = "number"
+	FieldTypeBool   = "bool"
+)
+
+// maxFieldValueLength is the length above which values aren't suggested, as
+// they are free text (messages, stack traces) rather than filterable values
+const maxFieldValueLength = 200
+
+// derivedLabels are labels added by GetLogLabels for Grafana that aren't
+// fields of a log entry, and so can't be used in filters
+var derivedLabels = map[string]bool{
+	"id":      true,
+	"level":   true,
+	"traceId": true,
+}
+
+// FieldSummary describes a field observed in a sample of log entries
+type FieldSummary struct {
+	// Path is the field path as written in filters, e.g. `resource.labels.zone`
+	Path string `json:"path"`
+	// Type is the observed type of the values, FieldTypeString if mixed
+	Type string `json:"type"`
+	// Count is the number of entries the field was found in
+	Count int64 `json:"count"`
+	// Values are the most frequent values, most frequent first
+	Values []FieldValueCount `json:"values"`
+}
+
+// FieldValueCount is a field value and the number of entries it was found in
+type FieldValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SummarizeFields lists the fields of the given entries, as flattened by
+// GetLogLabels, with their types and topN most frequent values. Fields are
+// sorted by path.
+func SummarizeFields(entries []*loggingpb.LogEntry, topN int) []FieldSummary {
+	type fieldStats struct {
+		count  int64
+		types  map[string]bool
+		values map[string]int64
+	}
+	fields := map[string]*fieldStats{}
+	observe := func(path string, value string) {
+		stats, ok := fields[path]
+		if !ok {
+			stats = &fieldStats{types: map[string]bool{}, values: map[string]int64{}}
+			fields[path] = stats
+		}
+		stats.count++
+		stats.types[valueType(value)] = true
+		if len(value) <= maxFieldValueLength {
+			stats.values[value]++
+		}
+	}
+
+	for _, entry := range entries {
+		for path, value := range GetLogLabels(entry) {
+			if !derivedLabels[path] {
+				observe(path, value)
+			}
+		}
+		observe("severity", entry.GetSeverity().String())
+	}
+
+	summaries := make([]FieldSummary, 0, len(fields))
+	for path, stats := range fields {
+		summary := FieldSummary{
+			Path:   path,
+			Type:   FieldTypeString,
+			Count:  stats.count,
+			Values: make([]FieldValueCount, 0, len(stats.values)),
+		}
+		if len(stats.types) == 1 {
+			for t := range stats.types {
+				summary.Type = t
+			}
+		}
+		for value, count := range stats.values {
+			summary.Values = append(summary.Values, FieldValueCount{Value: value, Count: count})
+		}
+		sort.Slice(summary.Values, func(i, j int) bool {
+			if summary.Values[i].Count != summary.Values[j].Count {
+				return summary.Values[i].Count > summary.Values[j].Count
+			}
+			return summary.Values[i].Value < summary.Values[j].Value
+		})
+		if len(summary.Values) > topN {
+			summary.Values = summary.Values[:topN]
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Path < summaries[j].Path
+	})
+	return summaries
+}
+
+// ValidateFieldPath checks that path is a single field path, such as
+// `resource.labels.zone` or `labels."k8s-pod/app"`
+func ValidateFieldPath(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return errors.New("missing field path")
+	}
+	expr, err := ParseFilter(path + ":*")
+	if err != nil {
+		return fmt.Errorf("invalid field path %q", path)
+	}
+	if r, ok := expr.(*Restriction); !ok || r.Field != path || r.Operator != ":" {
+		return fmt.Errorf("invalid field path %q", path)
+	}
+	return nil
+}
+
+// FieldValues counts the distinct values of a field of the given entries, as
+// flattened by GetLogLabels, and returns the limit most frequent sorted by
+// value, along with the number of distinct values found. User labels can be
+// referenced without quoting their key, as `labels.app`.
+func FieldValues(entries []*loggingpb.LogEntry, path string, limit int) ([]FieldValueCount, int) {
+	counts := map[string]int64{}
+	for _, entry := range entries {
+		if value, ok := fieldValue(entry, path); ok {
+			counts[value]++
+		}
+	}
+
+	values := make([]FieldValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, FieldValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	total := len(values)
+	if limit > 0 && total > limit {
+		values = values[:limit]
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Value < values[j].Value
+	})
+	return values, total
+}
+
+// fieldValue returns the value of the field path of an entry
+func fieldValue(entry *loggingpb.LogEntry, path string) (string, bool) {
+	if path == "severity" {
+		return entry.GetSeverity().String(), true
+	}
+	if path == "insertId" {
+		return entry.GetInsertId(), entry.GetInsertId() != ""
+	}
+	if derivedLabels[path] {
+		return "", false
+	}
+	labels := GetLogLabels(entry)
+	if value, ok := labels[path]; ok {
+		return value, true
+	}
+	if key, ok := strings.CutPrefix(path, "labels."); ok && !strings.HasPrefix(key, `"`) {
+		value, ok := labels[fmt.Sprintf("labels.\"%s\"", key)]
+		return value, ok
+	}
+	return "", false
+}
+
+// valueType infers the type of a value flattened to a string by GetLogLabels
+func valueType(value string) string {
+	if value == "true" || value == "false" {
+		return FieldTypeBool
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return FieldTypeNumber
+	}
+	return FieldTypeString
+}
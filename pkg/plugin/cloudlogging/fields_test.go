@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSummarizeFields(t *testing.T) {
+	t.Parallel()
+	entry := func(zone string, status float64, cached bool, detail string) *loggingpb.LogEntry {
+		payload, err := structpb.NewStruct(map[string]any{
+			"status": status,
+			"cached": cached,
+			"detail": detail,
+		})
+		require.NoError(t, err)
+		return &loggingpb.LogEntry{
+			InsertId: "id",
+			Severity: ltype.LogSeverity_ERROR,
+			Resource: &monitoredres.MonitoredResource{
+				Type:   "gce_instance",
+				Labels: map[string]string{"zone": zone},
+			},
+			Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+		}
+	}
+	entries := []*loggingpb.LogEntry{
+		entry("us-east1-b", 200, true, "ok"),
+		entry("us-east1-b", 500, false, "failed"),
+		entry("us-west1-a", 200, true, strings.Repeat("x", 300)),
+	}
+
+	fields := cloudlogging.SummarizeFields(entries, 1)
+	byPath := map[string]cloudlogging.FieldSummary{}
+	for _, f := range fields {
+		byPath[f.Path] = f
+	}
+
+	for _, derived := range []string{"id", "level", "traceId"} {
+		require.NotContains(t, byPath, derived)
+	}
+
+	zone := byPath["resource.labels.zone"]
+	require.Equal(t, cloudlogging.FieldTypeString, zone.Type)
+	require.Equal(t, int64(3), zone.Count)
+	require.Equal(t, []cloudlogging.FieldValueCount{{Value: "us-east1-b", Count: 2}}, zone.Values)
+
+	require.Equal(t, cloudlogging.FieldTypeNumber, byPath["jsonPayload.status"].Type)
+	require.Equal(t, cloudlogging.FieldTypeBool, byPath["jsonPayload.cached"].Type)
+	require.Equal(t, []cloudlogging.FieldValueCount{{Value: "ERROR", Count: 3}}, byPath["severity"].Values)
+
+	// Long free text values aren't suggested
+	detail := byPath["jsonPayload.detail"]
+	require.Equal(t, int64(3), detail.Count)
+	require.Equal(t, []cloudlogging.FieldValueCount{{Value: "failed", Count: 1}}, detail.Values)
+
+	for i := 1; i < len(fields); i++ {
+		require.Less(t, fields[i-1].Path, fields[i].Path)
+	}
+}
+
+func TestValidateFieldPath(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		path  string
+		valid bool
+	}{
+		{path: "resource.labels.zone", valid: true},
+		{path: `labels."k8s-pod/app"`, valid: true},
+		{path: "severity", valid: true},
+		{path: "", valid: false},
+		{path: "severity OR true", valid: false},
+		{path: "jsonPayload.a=b", valid: false},
+		{path: `"text"`, valid: false},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+			err := cloudlogging.ValidateFieldPath(tc.path)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestFieldValues(t *testing.T) {
+	t.Parallel()
+	entry := func(app string, severity ltype.LogSeverity) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			Labels:   map[string]string{"app": app},
+			Severity: severity,
+		}
+	}
+	entries := []*loggingpb.LogEntry{
+		entry("web", ltype.LogSeverity_ERROR),
+		entry("api", ltype.LogSeverity_INFO),
+		entry("web", ltype.LogSeverity_ERROR),
+		entry("worker", ltype.LogSeverity_ERROR),
+		{},
+	}
+
+	values, total := cloudlogging.FieldValues(entries, "labels.app", 2)
+	require.Equal(t, 3, total)
+	// The most frequent values are kept, sorted by value
+	require.Equal(t, []cloudlogging.FieldValueCount{{Value: "api", Count: 1}, {Value: "web", Count: 2}}, values)
+
+	values, total = cloudlogging.FieldValues(entries, `labels."app"`, 0)
+	require.Equal(t, 3, total)
+	require.Len(t, values, 3)
+
+	values, _ = cloudlogging.FieldValues(entries, "severity", 10)
+	require.Equal(t, []cloudlogging.FieldValueCount{{Value: "DEFAULT", Count: 1}, {Value: "ERROR", Count: 3}, {Value: "INFO", Count: 1}}, values)
+
+	values, total = cloudlogging.FieldValues(entries, "id", 10)
+	require.Zero(t, total)
+	require.Empty(t, values)
+}
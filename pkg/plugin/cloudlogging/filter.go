@@ -0,0 +1,541 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// This file implements a parser for the Logging query language, see
+// https://cloud.google.com/logging/docs/view/logging-query-language
+//
+// The parser is deliberately permissive about what it accepts as field names
+// and values, since the server is the authority on those: its purpose is to
+// catch structural mistakes (unbalanced parentheses and quotes, dangling
+// operators) early, with a position the query editor can point to.
+
+// comparisonOperators are the operators of the query language, longest first
+// so that `>=` isn't read as `>`
+var comparisonOperators = []string{">=", "<=", "!=", "=~", "!~", "=", "<", ">", ":"}
+
+// FilterSyntaxError is a syntax error in a Logging query language filter.
+// Line and Column are 1-based; Column counts characters, not bytes.
+type FilterSyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// FilterExpr is a node of a parsed filter
+type FilterExpr interface {
+	// String returns the expression fully parenthesized, to make the
+	// precedence of its operators explicit
+	String() string
+}
+
+// AndExpr is a conjunction, explicit (`a AND b`) or implicit (`a b`)
+type AndExpr struct {
+	Terms []FilterExpr
+}
+
+func (e *AndExpr) String() string {
+	return "(" + joinExprs(e.Terms, " AND ") + ")"
+}
+
+// OrExpr is a disjunction. OR binds tighter than AND.
+type OrExpr struct {
+	Terms []FilterExpr
+}
+
+func (e *OrExpr) String() string {
+	return "(" + joinExprs(e.Terms, " OR ") + ")"
+}
+
+// NotExpr is a negation, written `NOT a` or `-a`
+type NotExpr struct {
+	Expr FilterExpr
+}
+
+func (e *NotExpr) String() string {
+	return "NOT " + e.Expr.String()
+}
+
+// Restriction compares a field with a value, such as `severity >= ERROR`.
+// Global restrictions, which search all fields, have no field or operator.
+type Restriction struct {
+	Field    string
+	Operator string
+	Value    FilterExpr
+}
+
+func (e *Restriction) String() string {
+	if e.Field == "" {
+		return e.Value.String()
+	}
+	return e.Field + " " + e.Operator + " " + e.Value.String()
+}
+
+// Value is a literal, as written in the filter including quotes if any
+type Value struct {
+	Text string
+}
+
+func (e *Value) String() string {
+	return e.Text
+}
+
+// FunctionCall is a call to a built-in function such as `sample(insertId, 0.1)`
+type FunctionCall struct {
+	Name string
+	Args []string
+}
+
+func (e *FunctionCall) String() string {
+	return e.Name + "(" + strings.Join(e.Args, ", ") + ")"
+}
+
+func joinExprs(exprs []FilterExpr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, sep)
+}
+
+// ParseFilter parses a filter written in the Logging query language. An
+// empty filter, or one made only of comments, parses to a nil expression.
+// Syntax errors are returned as *FilterSyntaxError.
+func ParseFilter(filter string) (FilterExpr, error) {
+	p := &filterParser{input: []rune(filter)}
+	expr, err := p.parseAnd(false, p.parseRestriction)
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// ValidateFilter checks a filter for syntax errors
+func ValidateFilter(filter string) error {
+	_, err := ParseFilter(filter)
+	return err
+}
+
+// filterParser is a recursive descent parser working directly on the runes
+// of the filter, since how a run of characters is tokenized depends on where
+// it appears (e.g. `:` is an operator after a field, but part of a value).
+type filterParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *filterParser) errorAt(pos int, format string, args ...interface{}) error {
+	line, column := 1, 1
+	for _, r := range p.input[:pos] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &FilterSyntaxError{Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *filterParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// skipSpace skips whitespace and `--` comments
+func (p *filterParser) skipSpace() {
+	for !p.eof() {
+		if unicode.IsSpace(p.peek()) {
+			p.pos++
+		} else if p.hasPrefix("--") {
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (p *filterParser) hasPrefix(s string) bool {
+	r := []rune(s)
+	if p.pos+len(r) > len(p.input) {
+		return false
+	}
+	return string(p.input[p.pos:p.pos+len(r)]) == s
+}
+
+// keyword reports whether the boolean operator kw is at the current position.
+// Operators are case-sensitive: lowercase `and` is a search term.
+func (p *filterParser) keyword(kw string) bool {
+	if !p.hasPrefix(kw) {
+		return false
+	}
+	end := p.pos + len(kw)
+	return end == len(p.input) || !isWordRune(p.input[end])
+}
+
+// isWordRune reports whether r can be part of an unquoted field name or
+// search term
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()",=!<>:~`, r)
+}
+
+// isValueRune reports whether r can be part of an unquoted value, which
+// unlike field names may contain operator characters (e.g. times or URLs)
+func isValueRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()"`, r)
+}
+
+// parseAnd parses a sequence of terms joined by AND or juxtaposition, up to
+// the end of the input or, if closing is set, a closing parenthesis
+func (p *filterParser) parseAnd(closing bool, primary func() (FilterExpr, error)) (FilterExpr, error) {
+	var terms []FilterExpr
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		if p.peek() == ')' {
+			if closing {
+				break
+			}
+			return nil, p.errorAt(p.pos, "unexpected ')' without matching '('")
+		}
+		if len(terms) > 0 && p.keyword("AND") {
+			andPos := p.pos
+			p.pos += len("AND")
+			p.skipSpace()
+			if p.eof() || p.peek() == ')' {
+				return nil, p.errorAt(andPos, "expected an expression after AND")
+			}
+		}
+		term, err := p.parseOr(primary)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	switch len(terms) {
+	case 0:
+		return nil, nil
+	case 1:
+		return terms[0], nil
+	default:
+		return &AndExpr{Terms: terms}, nil
+	}
+}
+
+func (p *filterParser) parseOr(primary func() (FilterExpr, error)) (FilterExpr, error) {
+	first, err := p.parseNot(primary)
+	if err != nil {
+		return nil, err
+	}
+	terms := []FilterExpr{first}
+	for {
+		save := p.pos
+		p.skipSpace()
+		if !p.keyword("OR") {
+			p.pos = save
+			break
+		}
+		orPos := p.pos
+		p.pos += len("OR")
+		p.skipSpace()
+		if p.eof() || p.peek() == ')' {
+			return nil, p.errorAt(orPos, "expected an expression after OR")
+		}
+		term, err := p.parseNot(primary)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &OrExpr{Terms: terms}, nil
+}
+
+func (p *filterParser) parseNot(primary func() (FilterExpr, error)) (FilterExpr, error) {
+	p.skipSpace()
+	notPos := p.pos
+	switch {
+	case p.keyword("NOT"):
+		p.pos += len("NOT")
+	case p.peek() == '-' && p.pos+1 < len(p.input) && !unicode.IsSpace(p.input[p.pos+1]):
+		p.pos++
+	default:
+		return primary()
+	}
+	p.skipSpace()
+	if p.eof() || p.peek() == ')' {
+		return nil, p.errorAt(notPos, "expected an expression after NOT")
+	}
+	expr, err := p.parseNot(primary)
+	if err != nil {
+		return nil, err
+	}
+	return &NotExpr{Expr: expr}, nil
+}
+
+// parseGroup parses a parenthesized expression, the current rune being '('
+func (p *filterParser) parseGroup(primary func() (FilterExpr, error)) (FilterExpr, error) {
+	open := p.pos
+	p.pos++
+	expr, err := p.parseAnd(true, primary)
+	if err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, p.errorAt(open, "missing ')' to close '('")
+	}
+	p.pos++
+	if expr == nil {
+		return nil, p.errorAt(open, "empty parentheses")
+	}
+	return expr, nil
+}
+
+// checkTermStart rejects boolean operators and stray characters where a term
+// is expected
+func (p *filterParser) checkTermStart() error {
+	if p.eof() {
+		return p.errorAt(p.pos, "expected an expression")
+	}
+	for _, kw := range []string{"AND", "OR"} {
+		if p.keyword(kw) {
+			return p.errorAt(p.pos, "unexpected %s: expected an expression", kw)
+		}
+	}
+	return nil
+}
+
+// parseRestriction parses a term: a parenthesized expression, a comparison,
+// a function call or a global restriction
+func (p *filterParser) parseRestriction() (FilterExpr, error) {
+	if err := p.checkTermStart(); err != nil {
+		return nil, err
+	}
+	start := p.pos
+	switch r := p.peek(); {
+	case r == '(':
+		return p.parseGroup(p.parseRestriction)
+	case r == '"':
+		text, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return &Restriction{Value: &Value{Text: text}}, nil
+	case !isWordRune(r):
+		if p.operator() != "" {
+			return nil, p.errorAt(start, "missing field name before '%c'", r)
+		}
+		return nil, p.errorAt(start, "unexpected '%c'", r)
+	}
+
+	field, err := p.readField()
+	if err != nil {
+		return nil, err
+	}
+
+	var lhs FilterExpr
+	if p.peek() == '(' {
+		call, err := p.readFunctionCall(field)
+		if err != nil {
+			return nil, err
+		}
+		lhs = call
+	}
+
+	save := p.pos
+	p.skipSpace()
+	opPos := p.pos
+	op := p.operator()
+	if op == "" {
+		if p.peek() == '!' {
+			return nil, p.errorAt(opPos, "invalid operator '!': expected != or !~")
+		}
+		p.pos = save
+		if lhs != nil {
+			return lhs, nil
+		}
+		return &Restriction{Value: &Value{Text: field}}, nil
+	}
+	p.pos += len([]rune(op))
+	if lhs != nil {
+		field = lhs.String()
+	}
+
+	p.skipSpace()
+	if p.eof() || p.peek() == ')' || p.keyword("AND") || p.keyword("OR") {
+		return nil, p.errorAt(opPos, "expected a value after '%s'", op)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &Restriction{Field: field, Operator: op, Value: value}, nil
+}
+
+// operator returns the comparison operator at the current position, if any
+func (p *filterParser) operator() string {
+	for _, op := range comparisonOperators {
+		if p.hasPrefix(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// parseValue parses the right-hand side of a comparison: a string, an
+// unquoted value, or a parenthesized expression of values such as
+// `(ERROR OR WARNING)`
+func (p *filterParser) parseValue() (FilterExpr, error) {
+	if err := p.checkTermStart(); err != nil {
+		return nil, err
+	}
+	switch r := p.peek(); {
+	case r == '(':
+		return p.parseGroup(p.parseValue)
+	case r == '"':
+		text, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Text: text}, nil
+	case r == ')':
+		return nil, p.errorAt(p.pos, "unexpected ')': expected a value")
+	}
+	start := p.pos
+	for !p.eof() && isValueRune(p.peek()) {
+		p.pos++
+	}
+	return &Value{Text: string(p.input[start:p.pos])}, nil
+}
+
+// readField reads a field path such as `jsonPayload.user.id`, whose
+// components may be quoted: `labels."k8s-pod/app"`
+func (p *filterParser) readField() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		if isWordRune(p.peek()) {
+			p.pos++
+			continue
+		}
+		if p.peek() == '"' && p.pos > start && p.input[p.pos-1] == '.' {
+			if _, err := p.readString(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		break
+	}
+	return string(p.input[start:p.pos]), nil
+}
+
+// readString reads a double-quoted string, the current rune being '"'
+func (p *filterParser) readString() (string, error) {
+	start := p.pos
+	p.pos++
+	for !p.eof() {
+		switch p.peek() {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			return string(p.input[start:p.pos]), nil
+		}
+		p.pos++
+	}
+	p.pos = len(p.input)
+	return "", p.errorAt(start, "unterminated string")
+}
+
+// readFunctionCall reads the arguments of a call to name, the current rune
+// being '('
+func (p *filterParser) readFunctionCall(name string) (*FunctionCall, error) {
+	open := p.pos
+	p.pos++
+	call := &FunctionCall{Name: name}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorAt(open, "missing ')' to close '('")
+		}
+		if p.peek() == ')' && len(call.Args) == 0 {
+			p.pos++
+			return call, nil
+		}
+
+		var arg string
+		switch r := p.peek(); {
+		case r == '"':
+			text, err := p.readString()
+			if err != nil {
+				return nil, err
+			}
+			arg = text
+		case r != ',' && r != ')' && isValueRune(r):
+			start := p.pos
+			for !p.eof() && isValueRune(p.peek()) && p.peek() != ',' {
+				p.pos++
+			}
+			arg = string(p.input[start:p.pos])
+			if p.peek() == '(' {
+				nested, err := p.readFunctionCall(arg)
+				if err != nil {
+					return nil, err
+				}
+				arg = nested.String()
+			}
+		default:
+			return nil, p.errorAt(p.pos, "expected an argument of %s()", name)
+		}
+		call.Args = append(call.Args, arg)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return call, nil
+		default:
+			if p.eof() {
+				return nil, p.errorAt(open, "missing ')' to close '('")
+			}
+			return nil, p.errorAt(p.pos, "expected ',' or ')' in arguments of %s()", name)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		filter   string
+		expected string
+	}{
+		{
+			name:     "Comparison",
+			filter:   `resource.type = "gce_instance"`,
+			expected: `resource.type = "gce_instance"`,
+		},
+		{
+			name:     "Unquoted values and no spaces",
+			filter:   `severity>=ERROR`,
+			expected: `severity >= ERROR`,
+		},
+		{
+			name:     "Implicit AND across lines",
+			filter:   "resource.type=\"k8s_container\"\nseverity>=WARNING",
+			expected: `(resource.type = "k8s_container" AND severity >= WARNING)`,
+		},
+		{
+			name:     "OR binds tighter than AND",
+			filter:   `a=1 AND b=2 OR c=3`,
+			expected: `(a = 1 AND (b = 2 OR c = 3))`,
+		},
+		{
+			name:     "NOT binds tighter than OR",
+			filter:   `NOT a=1 OR -b=2`,
+			expected: `(NOT a = 1 OR NOT b = 2)`,
+		},
+		{
+			name:     "Parentheses",
+			filter:   `(a=1 AND b=2) OR c=3`,
+			expected: `((a = 1 AND b = 2) OR c = 3)`,
+		},
+		{
+			name:     "Value expressions",
+			filter:   `severity=(ERROR OR CRITICAL)`,
+			expected: `severity = (ERROR OR CRITICAL)`,
+		},
+		{
+			name:     "Quoted field path components",
+			filter:   `labels."k8s-pod/app":"api" jsonPayload.user\.id=~"^a.*"`,
+			expected: `(labels."k8s-pod/app" : "api" AND jsonPayload.user\.id =~ "^a.*")`,
+		},
+		{
+			name:     "Escaped quotes",
+			filter:   `textPayload:"say \"hi\""`,
+			expected: `textPayload : "say \"hi\""`,
+		},
+		{
+			name:     "Global restrictions",
+			filter:   `"connection refused" unicorn`,
+			expected: `("connection refused" AND unicorn)`,
+		},
+		{
+			name:     "Lowercase boolean operators are search terms",
+			filter:   `foo and bar`,
+			expected: `(foo AND and AND bar)`,
+		},
+		{
+			name:     "Functions",
+			filter:   `sample(insertId, 0.25) log_id("stdout") ip_in_net(jsonPayload.ip, "10.0.0.0/8")`,
+			expected: `(sample(insertId, 0.25) AND log_id("stdout") AND ip_in_net(jsonPayload.ip, "10.0.0.0/8"))`,
+		},
+		{
+			name:     "Existence and timestamps",
+			filter:   `jsonPayload.error:* timestamp>=2024-03-01T00:00:00Z`,
+			expected: `(jsonPayload.error : * AND timestamp >= 2024-03-01T00:00:00Z)`,
+		},
+		{
+			name:     "Comments",
+			filter:   "-- errors only\nseverity>=ERROR -- trailing\n-- done",
+			expected: `severity >= ERROR`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := cloudlogging.ParseFilter(tc.filter)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, expr.String())
+		})
+	}
+}
+
+func TestParseFilter_Empty(t *testing.T) {
+	t.Parallel()
+	for _, filter := range []string{"", "  \n ", "-- only a comment"} {
+		expr, err := cloudlogging.ParseFilter(filter)
+		require.NoError(t, err)
+		require.Nil(t, expr)
+	}
+}
+
+func TestParseFilter_SyntaxErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name    string
+		filter  string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name:    "Unterminated string",
+			filter:  "severity>=ERROR\ntextPayload:\"oops",
+			line:    2,
+			column:  13,
+			message: "unterminated string",
+		},
+		{
+			name:    "Unclosed parenthesis",
+			filter:  `(a=1 OR b=2`,
+			line:    1,
+			column:  1,
+			message: "missing ')'",
+		},
+		{
+			name:    "Unexpected closing parenthesis",
+			filter:  `a=1)`,
+			line:    1,
+			column:  4,
+			message: "unexpected ')'",
+		},
+		{
+			name:    "Missing value",
+			filter:  "severity >=\n",
+			line:    1,
+			column:  10,
+			message: "expected a value after '>='",
+		},
+		{
+			name:    "Dangling OR",
+			filter:  `a=1 OR`,
+			line:    1,
+			column:  5,
+			message: "expected an expression after OR",
+		},
+		{
+			name:    "Leading AND",
+			filter:  `AND a=1`,
+			line:    1,
+			column:  1,
+			message: "unexpected AND",
+		},
+		{
+			name:    "Missing field",
+			filter:  `= "x"`,
+			line:    1,
+			column:  1,
+			message: "missing field name",
+		},
+		{
+			name:    "Empty parentheses",
+			filter:  `a=1 ()`,
+			line:    1,
+			column:  5,
+			message: "empty parentheses",
+		},
+		{
+			name:    "Invalid operator",
+			filter:  `a ! b`,
+			line:    1,
+			column:  3,
+			message: "invalid operator",
+		},
+		{
+			name:    "Column counts characters",
+			filter:  `textPayload:"é" (`,
+			line:    1,
+			column:  17,
+			message: "missing ')'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := cloudlogging.ParseFilter(tc.filter)
+			var syntaxErr *cloudlogging.FilterSyntaxError
+			require.True(t, errors.As(err, &syntaxErr), "expected a syntax error, got %v", err)
+			require.Equal(t, tc.line, syntaxErr.Line)
+			require.Equal(t, tc.column, syntaxErr.Column)
+			require.Contains(t, syntaxErr.Message, tc.message)
+		})
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	t.Parallel()
+	q := cloudlogging.Query{Filter: "a=1 OR b=2 -- comment"}
+	q.TimeRange.From = "2024-03-01T00:00:00Z"
+	q.TimeRange.To = "2024-03-01T01:00:00Z"
+	require.Equal(t, "(\na=1 OR b=2 -- comment\n) AND timestamp >= \"2024-03-01T00:00:00Z\" AND timestamp <= \"2024-03-01T01:00:00Z\"", q.String())
+
+	q.Filter = "  -- nothing to see"
+	require.Equal(t, `timestamp >= "2024-03-01T00:00:00Z" AND timestamp <= "2024-03-01T01:00:00Z"`, q.String())
+
+	q.Restriction = `resource.labels.namespace_name="team-a" -- team A only`
+	require.Equal(t, "(\nresource.labels.namespace_name=\"team-a\" -- team A only\n) AND timestamp >= \"2024-03-01T00:00:00Z\" AND timestamp <= \"2024-03-01T01:00:00Z\"", q.String())
+
+	q.Filter = "a=1 OR b=2"
+	require.Equal(t, "(\nresource.labels.namespace_name=\"team-a\" -- team A only\n) AND (\na=1 OR b=2\n) AND timestamp >= \"2024-03-01T00:00:00Z\" AND timestamp <= \"2024-03-01T01:00:00Z\"", q.String())
+
+	// Restrictions made only of comments are left out too
+	q.Restriction = "\n  -- no restriction yet\n"
+	require.Equal(t, "(\na=1 OR b=2\n) AND timestamp >= \"2024-03-01T00:00:00Z\" AND timestamp <= \"2024-03-01T01:00:00Z\"", q.String())
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// contextWindow bounds how far before and after the anchor entry the context
+// queries look. Filters without a timestamp restriction only search the last
+// 24 hours, so an explicit window is needed for older entries anyway.
+const contextWindow = 24 * time.Hour
+
+// ContextAnchor identifies the log entry whose surrounding entries are fetched
+type ContextAnchor struct {
+	InsertID       string
+	Timestamp      time.Time
+	LogName        string
+	ResourceType   string
+	ResourceLabels map[string]string
+}
+
+// ContextQueries returns the queries for the limit entries logged right before
+// and right after the anchor entry, by the same log and resource. base
+// provides the project, bucket and view, or the resources of a log scope, to
+// query.
+//
+// Entries are ordered by timestamp, and Cloud Logging orders entries with the
+// same timestamp by insert ID, so ties with the anchor are broken by comparing
+// insert IDs. The before query returns the closest entry first.
+func ContextQueries(base Query, anchor ContextAnchor, limit int64) (before *Query, after *Query) {
+	ts := anchor.Timestamp.UTC().Format(time.RFC3339Nano)
+	scope := anchorScopeFilter(anchor)
+
+	before = &Query{
+		ProjectID: base.ProjectID,
+		BucketId:  base.BucketId,
+		ViewId:    base.ViewId,
+		Filter: scope + fmt.Sprintf(`(timestamp < "%s" OR (timestamp = "%s" AND insertId < "%s"))`,
+			ts, ts, QuoteFilterValue(anchor.InsertID)),
+		Limit:         limit,
+		Order:         OrderDescending,
+		ResourceNames: base.ResourceNames,
+	}
+	before.TimeRange.From = anchor.Timestamp.Add(-contextWindow).UTC().Format(time.RFC3339Nano)
+	before.TimeRange.To = ts
+
+	after = &Query{
+		ProjectID: base.ProjectID,
+		BucketId:  base.BucketId,
+		ViewId:    base.ViewId,
+		Filter: scope + fmt.Sprintf(`(timestamp > "%s" OR (timestamp = "%s" AND insertId > "%s"))`,
+			ts, ts, QuoteFilterValue(anchor.InsertID)),
+		Limit:         limit,
+		Order:         OrderAscending,
+		ResourceNames: base.ResourceNames,
+	}
+	after.TimeRange.From = ts
+	after.TimeRange.To = anchor.Timestamp.Add(contextWindow).UTC().Format(time.RFC3339Nano)
+
+	return before, after
+}
+
+// anchorScopeFilter restricts a filter to the log and resource of the anchor
+func anchorScopeFilter(anchor ContextAnchor) string {
+	var filter strings.Builder
+	if anchor.LogName != "" {
+		fmt.Fprintf(&filter, "logName=\"%s\"\n", QuoteFilterValue(anchor.LogName))
+	}
+	if anchor.ResourceType != "" {
+		fmt.Fprintf(&filter, "resource.type=\"%s\"\n", QuoteFilterValue(anchor.ResourceType))
+	}
+	keys := make([]string, 0, len(anchor.ResourceLabels))
+	for k := range anchor.ResourceLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&filter, "resource.labels.%s=\"%s\"\n", k, QuoteFilterValue(anchor.ResourceLabels[k]))
+	}
+	return filter.String()
+}
+
+// QuoteFilterValue escapes a value for use inside a double-quoted string of
+// the Logging query language. It escapes the same characters as the query
+// editor: backslashes, newlines and double quotes.
+func QuoteFilterValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextQueries(t *testing.T) {
+	t.Parallel()
+	anchor := cloudlogging.ContextAnchor{
+		InsertID:     `abc"123`,
+		Timestamp:    time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC),
+		LogName:      "projects/my-project/logs/stdout",
+		ResourceType: "k8s_container",
+		ResourceLabels: map[string]string{
+			"pod_name":       "api-1",
+			"namespace_name": "prod",
+		},
+	}
+
+	before, after := cloudlogging.ContextQueries(cloudlogging.Query{
+		ProjectID:     "my-project",
+		BucketId:      "global/buckets/my-bucket",
+		ResourceNames: []string{"projects/my-project", "projects/other-project"},
+	}, anchor, 5)
+
+	scope := "logName=\"projects/my-project/logs/stdout\"\n" +
+		"resource.type=\"k8s_container\"\n" +
+		"resource.labels.namespace_name=\"prod\"\n" +
+		"resource.labels.pod_name=\"api-1\"\n"
+
+	require.Equal(t, "my-project", before.ProjectID)
+	require.Equal(t, "global/buckets/my-bucket", before.BucketId)
+	require.Equal(t, int64(5), before.Limit)
+	require.Equal(t, []string{"projects/my-project", "projects/other-project"}, before.ResourceNames)
+	require.Equal(t, []string{"projects/my-project", "projects/other-project"}, after.ResourceNames)
+	require.Equal(t, cloudlogging.OrderDescending, before.Order)
+	require.Equal(t, scope+`(timestamp < "2024-03-01T12:00:00.123456789Z" OR (timestamp = "2024-03-01T12:00:00.123456789Z" AND insertId < "abc\"123"))`, before.Filter)
+	require.Equal(t, "2024-02-29T12:00:00.123456789Z", before.TimeRange.From)
+	require.Equal(t, "2024-03-01T12:00:00.123456789Z", before.TimeRange.To)
+
+	require.Equal(t, cloudlogging.OrderAscending, after.Order)
+	require.Equal(t, scope+`(timestamp > "2024-03-01T12:00:00.123456789Z" OR (timestamp = "2024-03-01T12:00:00.123456789Z" AND insertId > "abc\"123"))`, after.Filter)
+	require.Equal(t, "2024-03-01T12:00:00.123456789Z", after.TimeRange.From)
+	require.Equal(t, "2024-03-02T12:00:00.123456789Z", after.TimeRange.To)
+}
+
+func TestQuoteFilterValue(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, `a\\b\"c\nd`, cloudlogging.QuoteFilterValue("a\\b\"c\nd"))
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	loggingrest "google.golang.org/api/logging/v2"
+)
+
+// A log scope can include projects, or views as
+// `projects/my-project/locations/global/buckets/my-bucket/views/my-view`
+var (
+	projectResourcePattern = regexp.MustCompile(`^projects/([^/]+)$`)
+	viewResourcePattern    = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/buckets/([^/]+)/views/([^/]+)$`)
+)
+
+// LogScope is a named group of projects and log views that can be queried
+// together
+type LogScope struct {
+	// ID is the last segment of the name, as `my-log-scope`
+	ID string `json:"id"`
+	// Name is the resource name, as
+	// `projects/my-project/locations/global/logScopes/my-log-scope`
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	ResourceNames []string `json:"resourceNames"`
+}
+
+// LogScopeName is the resource name of a log scope of a project, given its
+// ID or resource name. Log scopes are only available in the global location.
+func LogScopeName(projectId string, scopeId string) string {
+	if strings.Contains(scopeId, "/") {
+		return scopeId
+	}
+	return fmt.Sprintf("projects/%s/locations/global/logScopes/%s", projectId, scopeId)
+}
+
+// ScopeResource is a project, or a view of one of its log buckets, included
+// in a log scope
+type ScopeResource struct {
+	ProjectID string
+	// BucketId is the bucket as `global/buckets/my-bucket`, empty for projects
+	BucketId string
+	ViewId   string
+}
+
+// ParseScopeResource parses a resource name of a log scope
+func ParseScopeResource(name string) (ScopeResource, error) {
+	if m := projectResourcePattern.FindStringSubmatch(name); m != nil {
+		return ScopeResource{ProjectID: m[1]}, nil
+	}
+	if m := viewResourcePattern.FindStringSubmatch(name); m != nil {
+		return ScopeResource{
+			ProjectID: m[1],
+			BucketId:  fmt.Sprintf("%s/buckets/%s", m[2], m[3]),
+			ViewId:    m[4],
+		}, nil
+	}
+	return ScopeResource{}, fmt.Errorf("unsupported log scope resource %q", name)
+}
+
+// logScopeFromREST converts a log scope of the REST API
+func logScopeFromREST(scope *loggingrest.LogScope) LogScope {
+	name := strings.Split(scope.Name, "/")
+	return LogScope{
+		ID:            name[len(name)-1],
+		Name:          scope.Name,
+		Description:   scope.Description,
+		ResourceNames: scope.ResourceNames,
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogScopeName(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "projects/my-project/locations/global/logScopes/checkout",
+		cloudlogging.LogScopeName("my-project", "checkout"))
+	require.Equal(t, "projects/other/locations/global/logScopes/checkout",
+		cloudlogging.LogScopeName("my-project", "projects/other/locations/global/logScopes/checkout"))
+}
+
+func TestParseScopeResource(t *testing.T) {
+	t.Parallel()
+	r, err := cloudlogging.ParseScopeResource("projects/my-project")
+	require.NoError(t, err)
+	require.Equal(t, cloudlogging.ScopeResource{ProjectID: "my-project"}, r)
+
+	r, err = cloudlogging.ParseScopeResource("projects/my-project/locations/us-central1/buckets/payments/views/_AllLogs")
+	require.NoError(t, err)
+	require.Equal(t, cloudlogging.ScopeResource{
+		ProjectID: "my-project",
+		BucketId:  "us-central1/buckets/payments",
+		ViewId:    "_AllLogs",
+	}, r)
+
+	_, err = cloudlogging.ParseScopeResource("folders/123")
+	require.ErrorContains(t, err, "unsupported log scope resource")
+}
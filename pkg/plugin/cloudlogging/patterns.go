@@ -0,0 +1,223 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+const (
+	// DefaultPatternSimilarity is the default fraction of tokens two messages
+	// must have in common to share a pattern
+	DefaultPatternSimilarity = 0.4
+	// DefaultMaxPatterns is the default number of patterns returned
+	DefaultMaxPatterns = 50
+	// DefaultSparklineBuckets is the default number of time buckets of a
+	// pattern's sparkline
+	DefaultSparklineBuckets = 20
+
+	// wildcardToken replaces the tokens that differ between the messages of a pattern
+	wildcardToken = "<*>"
+	// maxPatternSamples is how many insert IDs are kept as samples of a pattern
+	maxPatternSamples = 3
+)
+
+// variableTokenMasks replace the parts of a message that vary between
+// occurrences of the same log statement. Order matters: UUIDs and IPs contain
+// hex digits and numbers.
+var variableTokenMasks = []struct {
+	pattern *regexp.Regexp
+	mask    string
+}{
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<UUID>"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`), "<IP>"},
+	{regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b|\b(?:[0-9a-fA-F]{1,4}:){1,6}:(?:[0-9a-fA-F]{1,4}:){0,5}[0-9a-fA-F]{1,4}\b`), "<IP>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<HEX>"},
+}
+
+var (
+	// hexIDPattern matches candidate hex identifiers such as hashes and
+	// trace IDs. Only those mixing digits and letters are masked, so plain
+	// words ("deadbeef", "facade") are kept and numbers are masked as such.
+	hexIDPattern  = regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b`)
+	numberPattern = regexp.MustCompile(`[-+]?\b\d+(?:\.\d+)?`)
+)
+
+// PatternOptions tunes log pattern detection
+type PatternOptions struct {
+	// Similarity is the fraction of tokens, between 0 and 1, a message must
+	// have in common with a pattern to be grouped under it
+	Similarity float64
+	// MaxPatterns caps the number of patterns returned
+	MaxPatterns int
+	// SparklineBuckets is the number of time buckets each pattern's sparkline
+	// splits the From-To time range into
+	SparklineBuckets int
+	From             time.Time
+	To               time.Time
+}
+
+// Pattern is a template shared by similar log messages, with the tokens that
+// vary between them replaced by wildcards
+type Pattern struct {
+	Pattern string
+	Count   int64
+	// Sparkline counts the messages matching the pattern in each time bucket
+	Sparkline []int64
+	// SampleIDs are insert IDs of entries matching the pattern
+	SampleIDs []string
+
+	tokens []string
+}
+
+// MaskVariableTokens replaces numbers, hex identifiers, IPs and UUIDs of a
+// message with placeholders
+func MaskVariableTokens(message string) string {
+	for _, m := range variableTokenMasks {
+		message = m.pattern.ReplaceAllString(message, m.mask)
+	}
+	message = hexIDPattern.ReplaceAllStringFunc(message, func(token string) string {
+		if strings.ContainsAny(token, "0123456789") && strings.ContainsAny(token, "abcdefABCDEF") {
+			return "<HEX>"
+		}
+		return token
+	})
+	return numberPattern.ReplaceAllString(message, "<NUM>")
+}
+
+// DetectPatterns clusters the messages of the given entries into patterns
+// using a simplified version of the Drain algorithm: messages are grouped by
+// token count and first token, then merged into the most similar existing
+// pattern of the group. Patterns are sorted by count, most frequent first.
+// The total number of patterns found is returned along with the (possibly
+// truncated) patterns.
+func DetectPatterns(entries []*loggingpb.LogEntry, opts PatternOptions) ([]*Pattern, int) {
+	if opts.Similarity <= 0 || opts.Similarity > 1 {
+		opts.Similarity = DefaultPatternSimilarity
+	}
+	if opts.MaxPatterns <= 0 {
+		opts.MaxPatterns = DefaultMaxPatterns
+	}
+	if opts.SparklineBuckets <= 0 {
+		opts.SparklineBuckets = DefaultSparklineBuckets
+	}
+
+	groups := map[string][]*Pattern{}
+	patterns := []*Pattern{}
+	for _, entry := range entries {
+		message, err := GetLogEntryMessage(entry)
+		if err != nil {
+			continue
+		}
+		tokens := strings.Fields(MaskVariableTokens(message))
+		if len(tokens) == 0 {
+			continue
+		}
+
+		key := patternGroupKey(tokens)
+		pattern := mostSimilarPattern(groups[key], tokens, opts.Similarity)
+		if pattern == nil {
+			pattern = &Pattern{
+				tokens:    tokens,
+				Sparkline: make([]int64, opts.SparklineBuckets),
+			}
+			groups[key] = append(groups[key], pattern)
+			patterns = append(patterns, pattern)
+		} else {
+			for i, token := range tokens {
+				if pattern.tokens[i] != token {
+					pattern.tokens[i] = wildcardToken
+				}
+			}
+		}
+
+		pattern.Count++
+		pattern.Sparkline[sparklineBucket(entry.GetTimestamp().AsTime(), opts)]++
+		if len(pattern.SampleIDs) < maxPatternSamples {
+			pattern.SampleIDs = append(pattern.SampleIDs, entry.GetInsertId())
+		}
+	}
+
+	for _, pattern := range patterns {
+		pattern.Pattern = strings.Join(pattern.tokens, " ")
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].Count > patterns[j].Count
+	})
+	total := len(patterns)
+	if total > opts.MaxPatterns {
+		patterns = patterns[:opts.MaxPatterns]
+	}
+	return patterns, total
+}
+
+// patternGroupKey is the Drain prefix tree path of a message: its token count
+// and first token. A first token that was masked, or that is already a
+// variable, doesn't split the tree.
+func patternGroupKey(tokens []string) string {
+	first := tokens[0]
+	if strings.ContainsAny(first, "<0123456789") {
+		first = wildcardToken
+	}
+	return strconv.Itoa(len(tokens)) + " " + first
+}
+
+// mostSimilarPattern returns the pattern with the highest share of tokens
+// equal to tokens, if that share is at least similarity. Ties go to the
+// pattern with the most wildcards, as in Drain.
+func mostSimilarPattern(candidates []*Pattern, tokens []string, similarity float64) *Pattern {
+	var best *Pattern
+	bestScore, bestWildcards := -1.0, -1
+	for _, candidate := range candidates {
+		equal, wildcards := 0, 0
+		for i, token := range candidate.tokens {
+			if token == wildcardToken {
+				wildcards++
+			} else if token == tokens[i] {
+				equal++
+			}
+		}
+		score := float64(equal) / float64(len(tokens))
+		if score > bestScore || (score == bestScore && wildcards > bestWildcards) {
+			best, bestScore, bestWildcards = candidate, score, wildcards
+		}
+	}
+	if bestScore < similarity {
+		return nil
+	}
+	return best
+}
+
+// sparklineBucket returns the index of the sparkline bucket timestamp falls in
+func sparklineBucket(timestamp time.Time, opts PatternOptions) int {
+	span := opts.To.Sub(opts.From)
+	if span <= 0 {
+		return 0
+	}
+	bucket := int(int64(timestamp.Sub(opts.From)) * int64(opts.SparklineBuckets) / int64(span))
+	if bucket < 0 {
+		return 0
+	}
+	if bucket >= opts.SparklineBuckets {
+		return opts.SparklineBuckets - 1
+	}
+	return bucket
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMaskVariableTokens(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{
+			name:     "Numbers",
+			message:  "took 35ms to serve 12 items, ratio 0.75",
+			expected: "took <NUM>ms to serve <NUM> items, ratio <NUM>",
+		},
+		{
+			name:     "UUID",
+			message:  "user 123e4567-e89b-12d3-a456-426614174000 logged in",
+			expected: "user <UUID> logged in",
+		},
+		{
+			name:     "IPs",
+			message:  "connection from 10.0.0.12:5432 and 2001:db8::8a2e:370:7334 refused",
+			expected: "connection from <IP> and <IP> refused",
+		},
+		{
+			name:     "Hex identifiers",
+			message:  "commit 9fceb02d0ae598e95dc970b74767f19372d61af8 at 0x7ffee4c0 by deadbeef",
+			expected: "commit <HEX> at <HEX> by deadbeef",
+		},
+		{
+			name:     "Words with digits are kept",
+			message:  "GET /api/v2/users returned HTTP/1.1",
+			expected: "GET /api/v2/users returned HTTP/<NUM>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, cloudlogging.MaskVariableTokens(tc.message))
+		})
+	}
+}
+
+func TestDetectPatterns(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Minute)
+
+	entries := []*loggingpb.LogEntry{}
+	addEntry := func(offset time.Duration, message string) {
+		entries = append(entries, &loggingpb.LogEntry{
+			InsertId:  fmt.Sprintf("id-%d", len(entries)),
+			Timestamp: timestamppb.New(from.Add(offset)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: message},
+		})
+	}
+	for i := 0; i < 4; i++ {
+		addEntry(time.Duration(i)*time.Minute, fmt.Sprintf("user alice%d logged in from 10.0.0.%d", i, i))
+	}
+	addEntry(9*time.Minute, "user bob logged in from 10.1.2.3")
+	addEntry(4*time.Minute, "cache miss for key 42")
+	addEntry(5*time.Minute, "cache miss for key 43")
+	addEntry(7*time.Minute, "shutting down")
+
+	patterns, total := cloudlogging.DetectPatterns(entries, cloudlogging.PatternOptions{
+		MaxPatterns:      2,
+		SparklineBuckets: 5,
+		From:             from,
+		To:               to,
+	})
+	require.Equal(t, 3, total)
+	require.Len(t, patterns, 2)
+
+	require.Equal(t, "user <*> logged in from <IP>", patterns[0].Pattern)
+	require.Equal(t, int64(5), patterns[0].Count)
+	require.Equal(t, []int64{2, 2, 0, 0, 1}, patterns[0].Sparkline)
+	require.Equal(t, []string{"id-0", "id-1", "id-2"}, patterns[0].SampleIDs)
+
+	require.Equal(t, "cache miss for key <NUM>", patterns[1].Pattern)
+	require.Equal(t, int64(2), patterns[1].Count)
+	require.Equal(t, []int64{0, 0, 2, 0, 0}, patterns[1].Sparkline)
+}
+
+func TestDetectPatterns_Similarity(t *testing.T) {
+	t.Parallel()
+	entries := []*loggingpb.LogEntry{
+		{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "job build finished successfully"}},
+		{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "job deploy failed badly"}},
+	}
+
+	// Only 1 of 4 tokens in common
+	patterns, _ := cloudlogging.DetectPatterns(entries, cloudlogging.PatternOptions{Similarity: 0.5})
+	require.Len(t, patterns, 2)
+
+	patterns, _ = cloudlogging.DetectPatterns(entries, cloudlogging.PatternOptions{Similarity: 0.2})
+	require.Len(t, patterns, 1)
+	require.Equal(t, "job <*> <*> <*>", patterns[0].Pattern)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	resourcemanagerpb "cloud.google.com/go/resourcemanager/apiv3/resourcemanagerpb"
+)
+
+const (
+	// DefaultProjectPageSize is the number of projects listed per page when
+	// no page size is given
+	DefaultProjectPageSize = 100
+	// MaxProjectPageSize is the maximum page size of SearchProjects
+	MaxProjectPageSize = 500
+)
+
+var (
+	// See https://cloud.google.com/resource-manager/docs/labels-overview#requirements
+	projectLabelKeyPattern   = regexp.MustCompile(`^[\p{Ll}\p{Lo}][\p{Ll}\p{Lo}\p{N}_-]{0,62}$`)
+	projectLabelValuePattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}_-]{0,63}$`)
+	projectParentPattern     = regexp.MustCompile(`^(folders|organizations)/[0-9]+$`)
+)
+
+// ProjectQuery selects the projects to list
+type ProjectQuery struct {
+	// Search matches projects whose ID or display name contains each of its words
+	Search string
+	// Labels matches projects with all of the labels. An empty value matches
+	// any value of the label.
+	Labels map[string]string
+	// Parent matches the direct children of a folder or organization, as
+	// `folders/123` or `organizations/123`
+	Parent    string
+	PageSize  int32
+	PageToken string
+}
+
+// Project is the metadata of a GCP project
+type Project struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Number      string `json:"number"`
+	// Parent is the folder or organization of the project, as `folders/123`
+	// or `organizations/123`
+	Parent string            `json:"parent,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ProjectPage is a page of projects, and the token of the next page if any
+type ProjectPage struct {
+	Projects      []Project `json:"projects"`
+	NextPageToken string    `json:"nextPageToken,omitempty"`
+}
+
+// SearchFilter is the Resource Manager search query selecting the active
+// projects matching q. Search words are reduced to the characters allowed in
+// project IDs and display names so they can't alter the query, while invalid
+// labels and parents are rejected.
+func (q ProjectQuery) SearchFilter() (string, error) {
+	terms := []string{}
+
+	words := strings.FieldsFunc(q.Search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	for _, word := range words {
+		terms = append(terms, fmt.Sprintf("(id:*%s* OR displayName:*%s*)", word, word))
+	}
+
+	keys := make([]string, 0, len(q.Labels))
+	for key := range q.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := q.Labels[key]
+		if !projectLabelKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid label key %q", key)
+		}
+		if !projectLabelValuePattern.MatchString(value) {
+			return "", fmt.Errorf("invalid value %q of label %q", value, key)
+		}
+		if value == "" {
+			value = "*"
+		}
+		terms = append(terms, fmt.Sprintf("labels.%s:%s", key, value))
+	}
+
+	if q.Parent != "" {
+		if !projectParentPattern.MatchString(q.Parent) {
+			return "", fmt.Errorf("invalid parent %q: expected folders/<id> or organizations/<id>", q.Parent)
+		}
+		terms = append(terms, "parent:"+q.Parent)
+	}
+
+	terms = append(terms, "state:ACTIVE")
+	return strings.Join(terms, " AND "), nil
+}
+
+// projectFromProto converts a Resource Manager project
+func projectFromProto(p *resourcemanagerpb.Project) Project {
+	return Project{
+		ID:          p.GetProjectId(),
+		DisplayName: p.GetDisplayName(),
+		// Name is `projects/<number>`
+		Number: strings.TrimPrefix(p.GetName(), "projects/"),
+		Parent: p.GetParent(),
+		Labels: p.GetLabels(),
+	}
+}
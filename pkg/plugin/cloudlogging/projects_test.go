@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectQuerySearchFilter(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		query    cloudlogging.ProjectQuery
+		expected string
+		err      string
+	}{
+		{
+			name:     "Empty",
+			query:    cloudlogging.ProjectQuery{},
+			expected: "state:ACTIVE",
+		},
+		{
+			name:     "Search words",
+			query:    cloudlogging.ProjectQuery{Search: "my-proj prod"},
+			expected: "(id:*my-proj* OR displayName:*my-proj*) AND (id:*prod* OR displayName:*prod*) AND state:ACTIVE",
+		},
+		{
+			name:     "Search can't alter the query",
+			query:    cloudlogging.ProjectQuery{Search: `a* OR labels.x:"y"`},
+			expected: "(id:*a* OR displayName:*a*) AND (id:*OR* OR displayName:*OR*) AND (id:*labels* OR displayName:*labels*) AND (id:*x* OR displayName:*x*) AND (id:*y* OR displayName:*y*) AND state:ACTIVE",
+		},
+		{
+			name:     "Labels and parent",
+			query:    cloudlogging.ProjectQuery{Labels: map[string]string{"team": "", "env": "prod"}, Parent: "organizations/42"},
+			expected: "labels.env:prod AND labels.team:* AND parent:organizations/42 AND state:ACTIVE",
+		},
+		{
+			name:  "Invalid label key",
+			query: cloudlogging.ProjectQuery{Labels: map[string]string{"Env OR x": "prod"}},
+			err:   "invalid label key",
+		},
+		{
+			name:  "Invalid label value",
+			query: cloudlogging.ProjectQuery{Labels: map[string]string{"env": "prod*"}},
+			err:   "invalid value",
+		},
+		{
+			name:  "Invalid parent",
+			query: cloudlogging.ProjectQuery{Parent: "projects/1"},
+			err:   "invalid parent",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			filter, err := tc.query.SearchFilter()
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, filter)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RedactedValue replaces redacted values
+const RedactedValue = "[REDACTED]"
+
+// Built-in redaction patterns
+const (
+	RedactEmails      = "emails"
+	RedactBearerToken = "bearerTokens"
+	RedactCreditCards = "creditCards"
+	RedactIPv4        = "ipv4"
+	RedactIPv6        = "ipv6"
+)
+
+var redactionPresets = map[string]*regexp.Regexp{
+	RedactEmails:      regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	RedactBearerToken: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	RedactCreditCards: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	RedactIPv4:        regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	RedactIPv6:        regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b|\b(?:[0-9a-fA-F]{1,4}:){1,6}:(?:[0-9a-fA-F]{1,4}:){0,5}[0-9a-fA-F]{1,4}\b`),
+}
+
+// RedactionRules configure which values of log entries are redacted
+type RedactionRules struct {
+	// Fields are the paths of fields whose values are redacted, including
+	// nested fields, such as `jsonPayload.user` or `httpRequest.remoteIp`,
+	// and metadata such as `trace` or `sourceLocation`. The insert ID can be
+	// written `insertId` or `id`, as labeled by GetLogLabels.
+	Fields []string
+	// Patterns are regular expressions whose matches are redacted from all
+	// string values
+	Patterns []string
+	// Presets are names of built-in patterns, such as RedactEmails
+	Presets []string
+}
+
+// Redactor removes sensitive values from log entries. A nil Redactor redacts
+// nothing.
+type Redactor struct {
+	fields   []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor compiles redaction rules, returning nil if there are none
+func NewRedactor(rules RedactionRules) (*Redactor, error) {
+	r := &Redactor{}
+	for _, field := range rules.Fields {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		field = normalizeFieldPath(field)
+		if field == "id" {
+			field = "insertid"
+		}
+		r.fields = append(r.fields, field)
+	}
+	for _, pattern := range rules.Patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, preset := range rules.Presets {
+		re, ok := redactionPresets[preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction preset %q", preset)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	if len(r.fields) == 0 && len(r.patterns) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// normalizeFieldPath makes field paths comparable whichever way they're
+// written: `labels."app"` and `labels.app` are the same field, as are
+// `httpRequest.remoteIp` and `httpRequest.remote_ip` as labeled by GetLogLabels
+func normalizeFieldPath(path string) string {
+	return strings.ToLower(strings.NewReplacer(`"`, "", "_", "").Replace(path))
+}
+
+// RedactEntries redacts the entries in place, returning the number of
+// values redacted
+func (r *Redactor) RedactEntries(entries []*loggingpb.LogEntry) int {
+	if r == nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range entries {
+		count += r.redactEntry(entry)
+	}
+	return count
+}
+
+func (r *Redactor) redactEntry(entry *loggingpb.LogEntry) int {
+	count := r.redactMap("labels", entry.GetLabels())
+	count += r.redactMap("resource.labels", entry.GetResource().GetLabels())
+
+	switch t := entry.GetPayload().(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		var n int
+		t.TextPayload, n = r.redactString("textPayload", t.TextPayload)
+		count += n
+	case *loggingpb.LogEntry_JsonPayload:
+		count += r.redactStruct("jsonPayload", t.JsonPayload)
+	case *loggingpb.LogEntry_ProtoPayload:
+		count += r.redactProtoPayload(t)
+	}
+
+	if req := entry.GetHttpRequest(); req != nil {
+		count += r.redactStrings("httpRequest.", map[string]*string{
+			"requestMethod": &req.RequestMethod,
+			"requestUrl":    &req.RequestUrl,
+			"userAgent":     &req.UserAgent,
+			"remoteIp":      &req.RemoteIp,
+			"serverIp":      &req.ServerIp,
+			"referer":       &req.Referer,
+			"protocol":      &req.Protocol,
+		})
+	}
+
+	// Metadata that GetLogLabels shows as labels. Values that aren't strings
+	// can't be replaced, so they're cleared instead.
+	count += r.redactStrings("", map[string]*string{
+		"insertId": &entry.InsertId,
+		"logName":  &entry.LogName,
+		"trace":    &entry.Trace,
+		"spanId":   &entry.SpanId,
+	})
+	if res := entry.GetResource(); res != nil {
+		count += r.redactStrings("resource.", map[string]*string{"type": &res.Type})
+	}
+	if entry.TraceSampled && r.deniedField("traceSampled") {
+		entry.TraceSampled = false
+		count++
+	}
+	if entry.ReceiveTimestamp != nil && r.deniedField("receiveTimestamp") {
+		entry.ReceiveTimestamp = nil
+		count++
+	}
+	if op := entry.GetOperation(); op != nil {
+		count += r.redactStrings("operation.", map[string]*string{
+			"id":       &op.Id,
+			"producer": &op.Producer,
+		})
+		for name, value := range map[string]*bool{"first": &op.First, "last": &op.Last} {
+			if *value && r.deniedField("operation."+name) {
+				*value = false
+				count++
+			}
+		}
+	}
+	if loc := entry.GetSourceLocation(); loc != nil {
+		count += r.redactStrings("sourceLocation.", map[string]*string{
+			"file":     &loc.File,
+			"function": &loc.Function,
+		})
+		if loc.Line != 0 && r.deniedField("sourceLocation.line") {
+			loc.Line = 0
+			count++
+		}
+	}
+	return count
+}
+
+// redactStrings redacts the non-empty string fields of a message in place,
+// named after their paths under prefix
+func (r *Redactor) redactStrings(prefix string, fields map[string]*string) int {
+	count := 0
+	for name, value := range fields {
+		if *value == "" {
+			continue
+		}
+		var n int
+		*value, n = r.redactString(prefix+name, *value)
+		count += n
+	}
+	return count
+}
+
+// deniedField reports whether path is a denylisted field or nested in one
+func (r *Redactor) deniedField(path string) bool {
+	path = normalizeFieldPath(path)
+	for _, field := range r.fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString redacts the value of the field path, entirely if the field is
+// denylisted or else the pattern matches, returning whether it changed
+func (r *Redactor) redactString(path string, value string) (string, int) {
+	if r.deniedField(path) {
+		return RedactedValue, 1
+	}
+	redacted := value
+	for _, p := range r.patterns {
+		redacted = p.ReplaceAllStringFunc(redacted, func(match string) string {
+			if p == redactionPresets[RedactCreditCards] && !luhnValid(match) {
+				return match
+			}
+			return RedactedValue
+		})
+	}
+	if redacted != value {
+		return redacted, 1
+	}
+	return value, 0
+}
+
+func (r *Redactor) redactMap(path string, values map[string]string) int {
+	count := 0
+	for k, v := range values {
+		redacted, n := r.redactString(path+"."+k, v)
+		if n > 0 {
+			values[k] = redacted
+			count += n
+		}
+	}
+	return count
+}
+
+func (r *Redactor) redactStruct(path string, s *structpb.Struct) int {
+	count := 0
+	for k, v := range s.GetFields() {
+		fieldPath := path + "." + k
+		if r.deniedField(fieldPath) {
+			s.Fields[k] = structpb.NewStringValue(RedactedValue)
+			count++
+			continue
+		}
+		count += r.redactValue(fieldPath, v)
+	}
+	return count
+}
+
+func (r *Redactor) redactValue(path string, v *structpb.Value) int {
+	switch t := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		var n int
+		t.StringValue, n = r.redactString(path, t.StringValue)
+		return n
+	case *structpb.Value_StructValue:
+		return r.redactStruct(path, t.StructValue)
+	case *structpb.Value_ListValue:
+		count := 0
+		for _, item := range t.ListValue.GetValues() {
+			count += r.redactValue(path, item)
+		}
+		return count
+	}
+	return 0
+}
+
+// redactProtoPayload redacts a payload such as an audit log through its JSON
+// representation. As denylisted fields of the payload may not be strings,
+// they're removed rather than replaced. Payloads of unknown types are left
+// as they are, since they can't be read either.
+func (r *Redactor) redactProtoPayload(payload *loggingpb.LogEntry_ProtoPayload) int {
+	msg, err := payload.ProtoPayload.UnmarshalNew()
+	if err != nil {
+		return 0
+	}
+	js, err := protojson.Marshal(msg)
+	if err != nil {
+		return 0
+	}
+	s := &structpb.Struct{}
+	if err := protojson.Unmarshal(js, s); err != nil {
+		return 0
+	}
+
+	count := r.redactStruct("protoPayload", s)
+	if count == 0 {
+		return 0
+	}
+	var removeRedacted func(path string, s *structpb.Struct)
+	removeRedacted = func(path string, s *structpb.Struct) {
+		for k, v := range s.GetFields() {
+			if r.deniedField(path + "." + k) {
+				delete(s.Fields, k)
+			} else if nested := v.GetStructValue(); nested != nil {
+				removeRedacted(path+"."+k, nested)
+			}
+		}
+	}
+	removeRedacted("protoPayload", s)
+
+	redacted := msg.ProtoReflect().New().Interface()
+	if js, err = protojson.Marshal(s); err == nil {
+		err = protojson.Unmarshal(js, redacted)
+	}
+	if err == nil {
+		payload.ProtoPayload, err = anypb.New(redacted)
+	}
+	if err != nil {
+		// The redacted payload can't be shown, so none of it is
+		log.DefaultLogger.Warn("failed redacting proto payload", "error", err)
+		payload.ProtoPayload = &anypb.Any{TypeUrl: payload.ProtoPayload.GetTypeUrl()}
+	}
+	return count
+}
+
+// luhnValid reports whether the digits of a candidate card number pass the
+// Luhn checksum, to avoid redacting other long numbers
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// parallelFetchThreshold is the limit above which ListLogs reads slices
+	// of the time range in parallel rather than paging through it
+	parallelFetchThreshold = 5000
+	// maxFetchSlices is the maximum number of slices read in parallel
+	maxFetchSlices = 8
+	// pageRequestInterval and pageRequestBurst keep the page requests of
+	// log entry reads, sequential or parallel, within the default quota of
+	// 60 entries.list requests per minute. The burst covers the two pages
+	// each slice reads for its share of a limit of up to twice
+	// maxFetchSlices pages.
+	pageRequestInterval = time.Second
+	pageRequestBurst    = 2 * maxFetchSlices
+)
+
+// rateLimiter spaces out requests to one per interval, after a burst
+type rateLimiter struct {
+	interval time.Duration
+	burst    int
+
+	mu sync.Mutex
+	// next is when the next request would go out if there were no burst
+	next time.Time
+}
+
+func newRateLimiter(interval time.Duration, burst int) *rateLimiter {
+	return &rateLimiter{interval: interval, burst: burst}
+}
+
+// Wait blocks until a request can go out, or the context is done. A nil
+// rateLimiter doesn't limit requests.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now) - time.Duration(l.burst-1)*l.interval
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sliceReader reads the next n entries of a slice of a query's time range,
+// continuing where its previous call stopped. It reports whether the slice has
+// more entries, and on errors returns the entries read so far along with the
+// error.
+type sliceReader func(ctx context.Context, n int64) ([]*loggingpb.LogEntry, bool, error)
+
+// timeSlices splits the time range of a query into slices of the same
+// length, in the query's order, each with an even share of the query's limit.
+// Slices don't overlap, so an entry is only read once. It returns nil if the
+// time range can't be split.
+func timeSlices(q *Query) []Query {
+	from, err := time.Parse(time.RFC3339Nano, q.TimeRange.From)
+	if err != nil {
+		return nil
+	}
+	to, err := time.Parse(time.RFC3339Nano, q.TimeRange.To)
+	if err != nil || !from.Before(to) {
+		return nil
+	}
+
+	n := int((q.Limit + MaxPageSize - 1) / MaxPageSize)
+	if n > maxFetchSlices {
+		n = maxFetchSlices
+	}
+	if n < 2 {
+		return nil
+	}
+	share := (q.Limit + int64(n) - 1) / int64(n)
+
+	step := to.Sub(from) / time.Duration(n)
+	slices := make([]Query, n)
+	for i := range slices {
+		start := from.Add(time.Duration(i) * step)
+		end := from.Add(time.Duration(i+1)*step - time.Nanosecond)
+		if i == n-1 {
+			end = to
+		}
+		slices[i] = *q
+		slices[i].Limit = share
+		slices[i].TimeRange.From = start.Format(time.RFC3339Nano)
+		slices[i].TimeRange.To = end.Format(time.RFC3339Nano)
+	}
+	if q.Order != OrderAscending {
+		// Newest first
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			slices[i], slices[j] = slices[j], slices[i]
+		}
+	}
+	return slices
+}
+
+// fetchSlices reads the share of the limit of each slice of a query in
+// parallel, and passes their entries to fn in the order of the slices, page by
+// page, up to the limit. A slice is passed on once it and the slices before it
+// are read, and the reads of later slices are cancelled once the limit is
+// reached. When entries aren't spread evenly over the time range, a slice
+// holding more than its share is read further, one after another, until the
+// limit is reached or the slice runs out, so no more than twice the limit is
+// read overall.
+func fetchSlices(ctx context.Context, slices []Query, limit int64, open func(*Query) sliceReader, fn func([]*loggingpb.LogEntry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	g, gctx := errgroup.WithContext(ctx)
+	defer func() {
+		cancel()
+		_ = g.Wait()
+	}()
+
+	readers := make([]sliceReader, len(slices))
+	results := make([][]*loggingpb.LogEntry, len(slices))
+	more := make([]bool, len(slices))
+	errs := make([]error, len(slices))
+	done := make([]chan struct{}, len(slices))
+	for i := range slices {
+		i := i
+		readers[i] = open(&slices[i])
+		done[i] = make(chan struct{})
+		g.Go(func() error {
+			defer close(done[i])
+			results[i], more[i], errs[i] = readers[i](gctx, slices[i].Limit)
+			return errs[i]
+		})
+	}
+
+	// failed returns the error the reads failed with first: err, unless it
+	// comes from the cancellation of the reads by an earlier error
+	failed := func(err error) error {
+		cancel()
+		if first := g.Wait(); first != nil && !errors.Is(first, context.Canceled) {
+			return first
+		}
+		return err
+	}
+
+	var passed int64
+	pass := func(entries []*loggingpb.LogEntry) error {
+		if need := limit - passed; int64(len(entries)) > need {
+			entries = entries[:need]
+		}
+		for len(entries) > 0 {
+			n := len(entries)
+			if n > MaxPageSize {
+				n = MaxPageSize
+			}
+			if err := fn(entries[:n]); err != nil {
+				return err
+			}
+			passed += int64(n)
+			entries = entries[n:]
+		}
+		return nil
+	}
+
+	for i := range slices {
+		<-done[i]
+		if errs[i] != nil {
+			return failed(errs[i])
+		}
+		entries := results[i]
+		results[i] = nil
+		if err := pass(entries); err != nil {
+			return failed(err)
+		}
+		for passed < limit && more[i] {
+			n := limit - passed
+			if n > MaxPageSize {
+				n = MaxPageSize
+			}
+			page, hasMore, err := readers[i](gctx, n)
+			if err != nil {
+				return failed(err)
+			}
+			if err := pass(page); err != nil {
+				return failed(err)
+			}
+			more[i] = hasMore
+		}
+		if passed >= limit {
+			break
+		}
+	}
+	return nil
+}
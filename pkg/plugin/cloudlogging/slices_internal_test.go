@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeSlices(t *testing.T) {
+	t.Parallel()
+	q := &Query{Limit: 10000}
+	q.TimeRange.From = "2024-03-12T00:00:00Z"
+	q.TimeRange.To = "2024-03-12T08:00:00Z"
+
+	slices := timeSlices(q)
+	require.Len(t, slices, maxFetchSlices)
+	// Newest first, without overlapping
+	require.Equal(t, "2024-03-12T07:00:00Z", slices[0].TimeRange.From)
+	require.Equal(t, "2024-03-12T08:00:00Z", slices[0].TimeRange.To)
+	require.Equal(t, "2024-03-12T06:00:00Z", slices[1].TimeRange.From)
+	require.Equal(t, "2024-03-12T06:59:59.999999999Z", slices[1].TimeRange.To)
+	// Each with an even share of the limit
+	require.Equal(t, int64(1250), slices[1].Limit)
+
+	q.Order = OrderAscending
+	slices = timeSlices(q)
+	require.Equal(t, "2024-03-12T00:00:00Z", slices[0].TimeRange.From)
+
+	// As many slices as pages
+	q.Limit = 2500
+	require.Len(t, timeSlices(q), 3)
+
+	q.Limit = 500
+	require.Nil(t, timeSlices(q))
+	q.Limit = 10000
+	q.TimeRange.From = "yesterday"
+	require.Nil(t, timeSlices(q))
+}
+
+func TestFetchSlices(t *testing.T) {
+	t.Parallel()
+	slices := []Query{{ProjectID: "0", Limit: 2}, {ProjectID: "1", Limit: 2}, {ProjectID: "2", Limit: 2}}
+	insertIds := func(entries []*loggingpb.LogEntry) []string {
+		ids := []string{}
+		for _, e := range entries {
+			ids = append(ids, e.GetInsertId())
+		}
+		return ids
+	}
+	// reader returns readers of slices holding the given number of entries,
+	// adding up how many entries were read
+	reader := func(available map[string]int, read *int64, delay time.Duration) func(*Query) sliceReader {
+		var mu sync.Mutex
+		return func(q *Query) sliceReader {
+			left := available[q.ProjectID]
+			return func(ctx context.Context, n int64) ([]*loggingpb.LogEntry, bool, error) {
+				if q.ProjectID == "0" {
+					time.Sleep(delay)
+				}
+				entries := []*loggingpb.LogEntry{}
+				for int64(len(entries)) < n && left > 0 {
+					entries = append(entries, &loggingpb.LogEntry{InsertId: q.ProjectID})
+					left--
+				}
+				mu.Lock()
+				*read += int64(len(entries))
+				mu.Unlock()
+				return entries, left > 0, nil
+			}
+		}
+	}
+
+	// fetch collects the entries passed by fetchSlices
+	fetch := func(ctx context.Context, open func(*Query) sliceReader) ([]*loggingpb.LogEntry, error) {
+		entries := []*loggingpb.LogEntry{}
+		err := fetchSlices(ctx, slices, 6, open, func(page []*loggingpb.LogEntry) error {
+			entries = append(entries, page...)
+			return nil
+		})
+		return entries, err
+	}
+
+	// Entries are passed in the order of the slices, whichever ends first,
+	// and each slice only reads its share
+	var read int64
+	result, err := fetch(context.Background(), reader(map[string]int{"0": 2, "1": 2, "2": 2}, &read, 10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "0", "1", "1", "2", "2"}, insertIds(result))
+	require.Equal(t, int64(6), read)
+
+	// Slices holding more than their share are read further when others
+	// come up short
+	read = 0
+	result, err = fetch(context.Background(), reader(map[string]int{"0": 1, "1": 10, "2": 10}, &read, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1", "1", "1", "1", "1"}, insertIds(result))
+	require.LessOrEqual(t, read, int64(8))
+
+	// Later slices are cancelled once earlier ones reach the limit
+	result, err = fetch(context.Background(), func(q *Query) sliceReader {
+		left := 10
+		return func(ctx context.Context, n int64) ([]*loggingpb.LogEntry, bool, error) {
+			if q.ProjectID == "2" {
+				<-ctx.Done()
+				return nil, false, ctx.Err()
+			}
+			entries := []*loggingpb.LogEntry{}
+			for int64(len(entries)) < n && left > 0 {
+				entries = append(entries, &loggingpb.LogEntry{InsertId: q.ProjectID})
+				left--
+			}
+			return entries, left > 0, nil
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "0", "0", "0", "0", "0"}, insertIds(result))
+
+	_, err = fetch(context.Background(), func(q *Query) sliceReader {
+		return func(ctx context.Context, n int64) ([]*loggingpb.LogEntry, bool, error) {
+			if q.ProjectID == "1" {
+				return nil, false, errors.New("quota exceeded")
+			}
+			<-ctx.Done()
+			return nil, false, ctx.Err()
+		}
+	})
+	require.ErrorContains(t, err, "quota exceeded")
+
+	// Errors of fn stop the reads
+	err = fetchSlices(context.Background(), slices, 6, reader(map[string]int{"0": 2, "1": 2, "2": 2}, new(int64), 0), func([]*loggingpb.LogEntry) error {
+		return errors.New("budget exceeded")
+	})
+	require.ErrorContains(t, err, "budget exceeded")
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	l := newRateLimiter(time.Hour, 2)
+	require.NoError(t, l.Wait(context.Background()))
+	require.NoError(t, l.Wait(context.Background()))
+
+	// The burst is used up, so the next request waits for the interval
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+
+	var unlimited *rateLimiter
+	require.NoError(t, unlimited.Wait(context.Background()))
+}
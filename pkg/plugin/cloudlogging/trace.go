@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// traceWindow widens the time range of trace queries on both sides. Span
+// links set the time range to the span itself, but entries are often logged
+// around it, and with timestamps from clocks other than the tracer's.
+const traceWindow = time.Hour
+
+var (
+	// traceIDPattern matches the 16 or 32 hex digits of W3C, Cloud Trace and
+	// Jaeger trace IDs
+	traceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16}([0-9a-fA-F]{16})?$`)
+	// spanIDPattern matches the 16 hex digits of the spanId field
+	spanIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+)
+
+// TraceResourceName returns the `projects/<project>/traces/<id>` form of a
+// trace ID that the trace field of log entries holds. traceID is either a bare
+// trace ID of the project or already in that form.
+func TraceResourceName(projectID string, traceID string) (string, error) {
+	if traceID == "" {
+		return "", errors.New("missing required parameter: traceId")
+	}
+	id := traceID
+	if strings.HasPrefix(traceID, "projects/") {
+		parts := strings.Split(traceID, "/")
+		if len(parts) != 4 || parts[1] == "" || parts[2] != "traces" {
+			return "", fmt.Errorf("invalid trace %q: expected projects/<project>/traces/<id>", traceID)
+		}
+		projectID, id = parts[1], parts[3]
+	} else if projectID == "" {
+		return "", errors.New("missing required parameter: projectId")
+	}
+	if !traceIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid trace ID %q: expected 16 or 32 hex digits", id)
+	}
+	return fmt.Sprintf("projects/%s/traces/%s", projectID, strings.ToLower(id)), nil
+}
+
+// TraceQuery returns the query for the entries of a trace, or of one of its
+// spans if spanID is set, in ascending timestamp order. base provides the
+// project, bucket, view and limit, and its filter, if any, further narrows the
+// entries. The time range from..to is widened by traceWindow on both sides.
+func TraceQuery(base Query, traceID string, spanID string, from time.Time, to time.Time) (*Query, error) {
+	trace, err := TraceResourceName(base.ProjectID, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "trace=\"%s\"\n", QuoteFilterValue(trace))
+	if spanID != "" {
+		if !spanIDPattern.MatchString(spanID) {
+			return nil, fmt.Errorf("invalid span ID %q: expected 16 hex digits", spanID)
+		}
+		fmt.Fprintf(&filter, "spanId=\"%s\"\n", strings.ToLower(spanID))
+	}
+	if base.Filter != "" {
+		fmt.Fprintf(&filter, "(\n%s\n)", base.Filter)
+	}
+
+	q := &Query{
+		ProjectID:     base.ProjectID,
+		BucketId:      base.BucketId,
+		ViewId:        base.ViewId,
+		Filter:        strings.TrimSuffix(filter.String(), "\n"),
+		Limit:         base.Limit,
+		Order:         OrderAscending,
+		ResourceNames: base.ResourceNames,
+	}
+	q.TimeRange.From = from.Add(-traceWindow).UTC().Format(time.RFC3339)
+	q.TimeRange.To = to.Add(traceWindow).UTC().Format(time.RFC3339)
+	return q, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceResourceName(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		projectID string
+		traceID   string
+		want      string
+		wantErr   string
+	}{
+		"bare ID": {
+			projectID: "my-project",
+			traceID:   "4BF92F3577B34DA6A3CE929D0E0E4736",
+			want:      "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		"64-bit ID": {
+			projectID: "my-project",
+			traceID:   "a3ce929d0e0e4736",
+			want:      "projects/my-project/traces/a3ce929d0e0e4736",
+		},
+		"resource name of another project": {
+			projectID: "my-project",
+			traceID:   "projects/other-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+			want:      "projects/other-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		"missing ID": {
+			projectID: "my-project",
+			wantErr:   "traceId",
+		},
+		"missing project": {
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantErr: "projectId",
+		},
+		"not hex": {
+			projectID: "my-project",
+			traceID:   `4bf92f3577b34da6" OR "1`,
+			wantErr:   "invalid trace ID",
+		},
+		"malformed resource name": {
+			projectID: "my-project",
+			traceID:   "projects/other-project/spans/4bf92f3577b34da6a3ce929d0e0e4736",
+			wantErr:   "invalid trace",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := cloudlogging.TraceResourceName(tc.projectID, tc.traceID)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTraceQuery(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Second)
+
+	q, err := cloudlogging.TraceQuery(cloudlogging.Query{
+		ProjectID: "my-project",
+		BucketId:  "global/buckets/my-bucket",
+		Filter:    `severity>=WARNING`,
+		Limit:     100,
+	}, "4bf92f3577b34da6a3ce929d0e0e4736", "00F067AA0BA902B7", from, to)
+
+	require.NoError(t, err)
+	require.Equal(t, "my-project", q.ProjectID)
+	require.Equal(t, "global/buckets/my-bucket", q.BucketId)
+	require.Equal(t, int64(100), q.Limit)
+	require.Equal(t, cloudlogging.OrderAscending, q.Order)
+	require.Equal(t, "trace=\"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736\"\n"+
+		"spanId=\"00f067aa0ba902b7\"\n"+
+		"(\nseverity>=WARNING\n)", q.Filter)
+	require.Equal(t, "2024-03-01T11:00:00Z", q.TimeRange.From)
+	require.Equal(t, "2024-03-01T13:00:01Z", q.TimeRange.To)
+}
+
+func TestTraceQuery_InvalidSpanID(t *testing.T) {
+	t.Parallel()
+	_, err := cloudlogging.TraceQuery(cloudlogging.Query{ProjectID: "my-project"},
+		"4bf92f3577b34da6a3ce929d0e0e4736", "1234", time.Now(), time.Now())
+	require.ErrorContains(t, err, "invalid span ID")
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// errorGroupsFrame builds a table frame with one row per error group
+func errorGroupsFrame(groups []*cloudlogging.ErrorGroup) *data.Frame {
+	frame := data.NewFrame("errorGroups",
+		data.NewField("fingerprint", nil, []string{}),
+		data.NewField("exception", nil, []string{}),
+		data.NewField("language", nil, []string{}),
+		data.NewField("count", nil, []int64{}),
+		data.NewField("firstSeen", nil, []time.Time{}),
+		data.NewField("lastSeen", nil, []time.Time{}),
+		data.NewField("services", nil, []string{}),
+		data.NewField("sampleId", nil, []string{}),
+	)
+	for _, g := range groups {
+		frame.AppendRow(
+			g.Fingerprint,
+			g.Exception,
+			g.Language,
+			g.Count,
+			g.FirstSeen,
+			g.LastSeen,
+			strings.Join(g.Services, ", "),
+			g.SampleID,
+		)
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// maxExportEntries is the maximum number of entries exported at once
+const maxExportEntries = 100000
+
+// exportRequest is a parsed request of the export resource call
+type exportRequest struct {
+	query cloudlogging.Query
+	// logScope is the ID of a log scope to export from instead of the bucket
+	// and view, if any
+	logScope string
+	format   string
+	encoder  *cloudlogging.ExportEncoder
+}
+
+// exportRequestFromParams parses the parameters of the export resource call:
+// the ProjectId, BucketId and ViewId or LogScope to read from, the filter
+// query and the from and to RFC 3339 times, along with the optional format
+// (`ndjson` or `csv`), comma separated fields, order and limit
+func exportRequestFromParams(params url.Values) (*exportRequest, error) {
+	if params.Get("ProjectId") == "" {
+		return nil, fmt.Errorf("missing required parameter: ProjectId")
+	}
+	filter := params.Get("query")
+	if err := cloudlogging.ValidateFilter(filter); err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+
+	from, err := time.Parse(time.RFC3339Nano, params.Get("from"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from time %q: expected an RFC 3339 timestamp", params.Get("from"))
+	}
+	to, err := time.Parse(time.RFC3339Nano, params.Get("to"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid to time %q: expected an RFC 3339 timestamp", params.Get("to"))
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time range: from must be before to")
+	}
+
+	order := strings.ToLower(params.Get("order"))
+	if order != "" && order != cloudlogging.OrderDescending && order != cloudlogging.OrderAscending {
+		return nil, fmt.Errorf("invalid order %q: must be %q or %q", params.Get("order"), cloudlogging.OrderDescending, cloudlogging.OrderAscending)
+	}
+
+	limit := int64(maxExportEntries)
+	if l := params.Get("limit"); l != "" {
+		limit, err = strconv.ParseInt(l, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %q: must be a positive number", l)
+		}
+		if limit > maxExportEntries {
+			limit = maxExportEntries
+		}
+	}
+
+	format := params.Get("format")
+	if format == "" {
+		format = cloudlogging.ExportFormatNDJSON
+	}
+	fields := []string{}
+	for _, field := range strings.Split(params.Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	encoder, err := cloudlogging.NewExportEncoder(format, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &exportRequest{
+		query: cloudlogging.Query{
+			ProjectID: params.Get("ProjectId"),
+			BucketId:  params.Get("BucketId"),
+			ViewId:    params.Get("ViewId"),
+			Filter:    filter,
+			Limit:     limit,
+			Order:     order,
+		},
+		logScope: params.Get("LogScope"),
+		format:   format,
+		encoder:  encoder,
+	}
+	req.query.TimeRange.From = from.Format(time.RFC3339Nano)
+	req.query.TimeRange.To = to.Format(time.RFC3339Nano)
+	return req, nil
+}
+
+// exportLogs streams the entries matching an export request as they're read,
+// one chunk per page. Once the first chunk is sent the response can't fail
+// anymore, so later errors end the export early and are logged. The export
+// stops when the client disconnects, as the request context is cancelled.
+func (d *CloudLoggingDatasource) exportLogs(ctx context.Context, client cloudlogging.API, req *exportRequest, sender backend.CallResourceResponseSender) error {
+	filename := fmt.Sprintf("logs-%s.%s", time.Now().UTC().Format("20060102-150405"), req.format)
+	started := false
+	exported := 0
+
+	err := client.ListLogPages(ctx, &req.query, func(entries []*loggingpb.LogEntry) error {
+		d.redactor.RedactEntries(entries)
+		chunk, err := req.encoder.Encode(entries)
+		if err != nil {
+			return err
+		}
+		resp := &backend.CallResourceResponse{Body: chunk}
+		if !started {
+			resp.Status = http.StatusOK
+			resp.Headers = exportHeaders(req.encoder.ContentType(), filename)
+		}
+		if err := sender.Send(resp); err != nil {
+			return err
+		}
+		started = true
+		exported += len(entries)
+		return ctx.Err()
+	})
+	if err != nil {
+		if started {
+			log.DefaultLogger.Warn("export ended early", "exported", exported, "error", err)
+			return nil
+		}
+		log.DefaultLogger.Error("problem exporting logs", "error", err)
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+		})
+	}
+	if started {
+		return nil
+	}
+
+	// No entries matched, which is still a valid export with a CSV header
+	chunk, err := req.encoder.Encode(nil)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+			Body:   jsonErrorBody("Unable to create response"),
+		})
+	}
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: exportHeaders(req.encoder.ContentType(), filename),
+		Body:    chunk,
+	})
+}
+
+func exportHeaders(contentType string, filename string) map[string][]string {
+	return map[string][]string{
+		"Content-Type":        {contentType},
+		"Content-Disposition": {fmt.Sprintf("attachment; filename=%q", filename)},
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+)
+
+const (
+	// fieldSampleSize is how many recent entries are sampled to list fields
+	fieldSampleSize = 500
+	// fieldSampleWindow is how far back entries are sampled
+	fieldSampleWindow = time.Hour
+	// fieldCacheTTL is how long sampled fields are reused for a resource
+	fieldCacheTTL = 5 * time.Minute
+	// defaultFieldValues is how many values are returned per field when no
+	// limit is given
+	defaultFieldValues = 10
+	// maxFieldValues is the maximum number of values returned per field
+	maxFieldValues = 100
+)
+
+// ttlCache is a concurrency safe cache whose entries expire after a fixed
+// duration. Its zero value is an empty cache.
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// get returns the value cached under key, if it hasn't expired
+func (c *ttlCache[V]) get(key string, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set caches value under key until now + ttl, dropping expired entries
+func (c *ttlCache[V]) set(key string, value V, now time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]ttlCacheEntry[V]{}
+	}
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlCacheEntry[V]{value: value, expires: now.Add(ttl)}
+}
+
+// fieldValuesLimit reads the number of values per field of the fields
+// resource call
+func fieldValuesLimit(params url.Values) (int, error) {
+	limit := params.Get("Limit")
+	if limit == "" {
+		return defaultFieldValues, nil
+	}
+	l, err := strconv.Atoi(limit)
+	if err != nil || l < 0 {
+		return 0, fmt.Errorf("invalid limit %q", limit)
+	}
+	if l > maxFieldValues {
+		l = maxFieldValues
+	}
+	return l, nil
+}
+
+// listFields samples the recent entries of a project, bucket and view and
+// summarizes their fields. Summaries are cached per resource unless cache is
+// false, as when each user queries with their own credentials.
+func (d *CloudLoggingDatasource) listFields(ctx context.Context, client cloudlogging.API, projectID, bucketID, viewID string, limit int, cache bool) ([]cloudlogging.FieldSummary, error) {
+	key := strings.Join([]string{projectID, bucketID, viewID, strconv.Itoa(limit)}, "/")
+	now := time.Now()
+	if cache {
+		if fields, ok := d.fieldsCache.get(key, now); ok {
+			return fields, nil
+		}
+	}
+
+	q := &cloudlogging.Query{
+		ProjectID: projectID,
+		BucketId:  bucketID,
+		ViewId:    viewID,
+		Limit:     fieldSampleSize,
+	}
+	q.TimeRange.From = now.Add(-fieldSampleWindow).UTC().Format(time.RFC3339)
+	q.TimeRange.To = now.UTC().Format(time.RFC3339)
+	logs, err := client.ListLogs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	d.redactor.RedactEntries(logs)
+	fields := cloudlogging.SummarizeFields(logs, limit)
+	if cache {
+		d.fieldsCache.set(key, fields, now, fieldCacheTTL)
+	}
+	return fields, nil
+}
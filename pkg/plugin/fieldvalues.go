@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// fieldValuesSampleSize is how many of the most recent matching entries
+	// are read to find the values of a field
+	fieldValuesSampleSize = 2000
+	// fieldValuesCacheTTL is how long the values of a field are reused for
+	// identical queries, such as dashboards refreshing their variables
+	fieldValuesCacheTTL = time.Minute
+	// defaultVariableValues is how many values are returned when no limit is given
+	defaultVariableValues = 100
+	// maxVariableValues is the maximum number of values returned
+	maxVariableValues = 1000
+)
+
+// fieldValuesResult is the outcome of a fieldValues query
+type fieldValuesResult struct {
+	values []cloudlogging.FieldValueCount
+	// total is the number of distinct values found
+	total int
+	// sampled is set when more entries matched than were read
+	sampled bool
+	// redacted is the number of values redacted from the entries read
+	redacted int
+}
+
+// fieldValuesQuery handles the fieldValues query type, returning the distinct
+// values of a field of the entries matching the filter over the time range.
+// Results are cached for identical queries unless each user queries with
+// their own credentials.
+func (d *CloudLoggingDatasource) fieldValuesQuery(ctx context.Context, q queryModel, filter string, timeRange backend.TimeRange, client cloudlogging.API) backend.DataResponse {
+	response := backend.DataResponse{}
+
+	if err := cloudlogging.ValidateFieldPath(q.FieldPath); err != nil {
+		response.Error = fmt.Errorf("field values: %w", err)
+		return response
+	}
+	limit := q.MaxValues
+	if limit <= 0 {
+		limit = defaultVariableValues
+	}
+	if limit > maxVariableValues {
+		limit = maxVariableValues
+	}
+
+	// Relative time ranges such as "last 6 hours" move on every refresh, so
+	// they're rounded to the minute for identical queries to share results
+	from := timeRange.From.UTC().Truncate(time.Minute)
+	to := timeRange.To.UTC().Truncate(time.Minute)
+
+	hasField := q.FieldPath + ":*"
+	if filter != "" {
+		hasField = fmt.Sprintf("(\n%s\n) AND %s", filter, hasField)
+	}
+
+	key := strings.Join([]string{
+		q.ProjectID, q.BucketId, q.ViewId, strings.Join(q.resourceNames, ","), hasField, strconv.Itoa(limit),
+		from.Format(time.RFC3339), to.Format(time.RFC3339),
+	}, "\x00")
+	now := time.Now()
+	result, ok := d.fieldValuesCache.get(key, now)
+	if !ok || d.oauthPassThrough {
+		clientRequest := &cloudlogging.Query{
+			ProjectID:     q.ProjectID,
+			BucketId:      q.BucketId,
+			ViewId:        q.ViewId,
+			Filter:        hasField,
+			Limit:         fieldValuesSampleSize,
+			ResourceNames: q.resourceNames,
+		}
+		clientRequest.TimeRange.From = from.Format(time.RFC3339)
+		clientRequest.TimeRange.To = to.Format(time.RFC3339)
+
+		logs, err := client.ListLogs(ctx, clientRequest)
+		if err != nil {
+			response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
+			return response
+		}
+
+		result.redacted = d.redactor.RedactEntries(logs)
+		result.values, result.total = cloudlogging.FieldValues(logs, q.FieldPath, limit)
+		result.sampled = len(logs) >= fieldValuesSampleSize
+		if !d.oauthPassThrough {
+			d.fieldValuesCache.set(key, result, now, fieldValuesCacheTTL)
+		}
+	}
+
+	response.Frames = append(response.Frames, fieldValuesFrame(result))
+	if result.redacted > 0 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf(redactionNotice, result.redacted))
+	}
+	return response
+}
+
+// fieldValuesFrame builds a table frame with one row per distinct value
+func fieldValuesFrame(result fieldValuesResult) *data.Frame {
+	frame := data.NewFrame("fieldValues",
+		data.NewField("value", nil, []string{}),
+		data.NewField("count", nil, []int64{}),
+	)
+	for _, v := range result.values {
+		frame.AppendRow(v.Value, v.Count)
+	}
+
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	if result.total > len(result.values) {
+		frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("Showing the %d most frequent of %d values", len(result.values), result.total),
+		})
+	}
+	if result.sampled {
+		frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("Values were found in the %d most recent matching entries", fieldValuesSampleSize),
+		})
+	}
+	return frame
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Statuses of health checks
+const (
+	healthCheckOK      = "ok"
+	healthCheckWarning = "warning"
+	healthCheckError   = "error"
+	healthCheckSkipped = "skipped"
+)
+
+// Permissions tested on the default project by the health check
+const (
+	permissionListEntries = "logging.logEntries.list"
+	permissionListBuckets = "logging.buckets.list"
+	permissionListViews   = "logging.views.list"
+)
+
+// defaultBucket is the log bucket every project has, whose views are listed
+// by the health check
+const defaultBucket = "global/buckets/_Default"
+
+// healthCheck is the outcome of one of the checks of the health check
+type healthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// healthDetails are the JSONDetails of the health check. Grafana shows the
+// verbose message below the result's message.
+type healthDetails struct {
+	Project        string        `json:"project"`
+	Checks         []healthCheck `json:"checks"`
+	VerboseMessage string        `json:"verboseMessage"`
+}
+
+// diagnose checks that the client can get a token, read log entries, list
+// log buckets and views of the project, and search projects. Only failing to
+// get a token or to read log entries fails the health check, as the rest are
+// only needed by the query editor. Whether each permission is missing is
+// asked to Resource Manager, as other errors are ambiguous.
+func diagnose(ctx context.Context, client cloudlogging.API, projectID string) *backend.CheckHealthResult {
+	details := healthDetails{Project: projectID}
+	result := &backend.CheckHealthResult{Status: backend.HealthStatusOk}
+
+	if err := client.TestToken(ctx); err != nil {
+		details.Checks = append(details.Checks,
+			healthCheck{"Access token", healthCheckError, fmt.Sprintf("failed to get an access token: %s", sanitizeErrorMessage(err))},
+			healthCheck{"Log entries", healthCheckSkipped, ""},
+			healthCheck{"Log buckets", healthCheckSkipped, ""},
+			healthCheck{"Log views", healthCheckSkipped, ""},
+			healthCheck{"Project search", healthCheckSkipped, ""},
+		)
+		result.Status = backend.HealthStatusError
+		result.Message = details.Checks[0].Message
+		return withHealthDetails(result, details)
+	}
+	details.Checks = append(details.Checks, healthCheck{"Access token", healthCheckOK, "acquired an access token"})
+
+	// granted is nil if Resource Manager can't tell
+	var granted map[string]bool
+	if permissions, err := client.TestPermissions(ctx, projectID, []string{permissionListEntries, permissionListBuckets, permissionListViews}); err == nil {
+		granted = map[string]bool{}
+		for _, p := range permissions {
+			granted[p] = true
+		}
+	}
+	missing := func(permission string, err error) bool {
+		if granted != nil {
+			return !granted[permission]
+		}
+		return status.Code(err) == codes.PermissionDenied
+	}
+
+	entries := healthCheck{Name: "Log entries", Status: healthCheckOK}
+	err := client.TestConnection(ctx, projectID)
+	switch {
+	case err == nil:
+		entries.Message = fmt.Sprintf("read log entries of project %s", projectID)
+		result.Message = fmt.Sprintf("Successfully queried logs from GCP project %s", projectID)
+	case errors.Is(err, cloudlogging.ErrNoEntries) && !missing(permissionListEntries, nil):
+		entries.Status = healthCheckWarning
+		entries.Message = fmt.Sprintf("project %s has no log entries yet", projectID)
+		result.Message = fmt.Sprintf("Successfully connected to GCP project %s, which has no log entries yet", projectID)
+	case missing(permissionListEntries, err):
+		entries.Status = healthCheckError
+		entries.Message = fmt.Sprintf("missing permission %s on project %s, granted by roles/logging.viewer", permissionListEntries, projectID)
+	default:
+		entries.Status = healthCheckError
+		entries.Message = fmt.Sprintf("failed to run test query: %s", sanitizeErrorMessage(err))
+	}
+	details.Checks = append(details.Checks, entries)
+	if entries.Status == healthCheckError {
+		result.Status = backend.HealthStatusError
+		result.Message = entries.Message
+	}
+
+	buckets := healthCheck{Name: "Log buckets", Status: healthCheckOK}
+	if bucketList, err := client.ListProjectBuckets(ctx, projectID); err == nil {
+		buckets.Message = fmt.Sprintf("found %d log buckets", len(bucketList))
+	} else if missing(permissionListBuckets, err) {
+		buckets.Status = healthCheckWarning
+		buckets.Message = fmt.Sprintf("missing permission %s: log buckets can't be selected in the query editor", permissionListBuckets)
+	} else {
+		buckets.Status = healthCheckWarning
+		buckets.Message = fmt.Sprintf("failed to list log buckets: %s", sanitizeErrorMessage(err))
+	}
+	details.Checks = append(details.Checks, buckets)
+
+	views := healthCheck{Name: "Log views", Status: healthCheckOK}
+	if viewList, err := client.ListProjectBucketViews(ctx, projectID, defaultBucket); err == nil {
+		views.Message = fmt.Sprintf("found %d views of the _Default log bucket", len(viewList))
+	} else if missing(permissionListViews, err) {
+		views.Status = healthCheckWarning
+		views.Message = fmt.Sprintf("missing permission %s: log views can't be selected in the query editor", permissionListViews)
+	} else {
+		views.Status = healthCheckWarning
+		views.Message = fmt.Sprintf("failed to list log views: %s", sanitizeErrorMessage(err))
+	}
+	details.Checks = append(details.Checks, views)
+
+	projects := healthCheck{Name: "Project search", Status: healthCheckOK, Message: "searched projects"}
+	if _, err := client.ListProjects(ctx, cloudlogging.ProjectQuery{PageSize: 1}); err != nil {
+		projects.Status = healthCheckWarning
+		projects.Message = fmt.Sprintf("failed to search projects, check that the Cloud Resource Manager API is enabled: %s", sanitizeErrorMessage(err))
+	}
+	details.Checks = append(details.Checks, projects)
+
+	return withHealthDetails(result, details)
+}
+
+// withHealthDetails sets the JSONDetails of a health check result
+func withHealthDetails(result *backend.CheckHealthResult, details healthDetails) *backend.CheckHealthResult {
+	lines := make([]string, 0, len(details.Checks))
+	for _, c := range details.Checks {
+		line := fmt.Sprintf("[%s] %s", c.Status, c.Name)
+		if c.Message != "" {
+			line += ": " + c.Message
+		}
+		lines = append(lines, line)
+	}
+	details.VerboseMessage = strings.Join(lines, "\n")
+
+	body, err := json.Marshal(details)
+	if err == nil {
+		result.JSONDetails = body
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+)
+
+// defaultMaxEntries is the maximum number of entries a query can ask for,
+// unless the data source sets another
+const defaultMaxEntries = 10000
+
+// entryLimit returns the number of entries to read for a query: its limit if
+// set, else the number of data points Grafana asks for, which depends on the
+// width of the panel, up to the data source's maximum. It reports whether the
+// limit was set by the query or the data source's maximum, rather than the
+// panel, as only those limits are worth telling users about when reached.
+func (d *CloudLoggingDatasource) entryLimit(q queryModel, maxDataPoints int64) (int64, bool, error) {
+	if q.Limit < 0 {
+		return 0, false, fmt.Errorf("invalid limit %d: must be a positive number", q.Limit)
+	}
+	limit := maxDataPoints
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+
+	ceiling := d.maxEntries
+	if ceiling <= 0 {
+		ceiling = defaultMaxEntries
+	}
+	if limit > ceiling {
+		return ceiling, true, nil
+	}
+	return limit, q.Limit > 0, nil
+}
+
+// truncationNotice tells users that the query read as many entries as its
+// limit, so more entries may match
+const truncationNotice = "Results were limited to %d entries: raise the query's limit or narrow its time range or filter to see more"
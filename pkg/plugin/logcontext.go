@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// defaultContextLimit is how many entries are returned before and after
+	// the anchor entry when no limit is given
+	defaultContextLimit = 10
+	// maxContextLimit is the maximum page size of ListLogEntries
+	maxContextLimit = 1000
+)
+
+// logContextModel is the anchor entry of the logContext query type
+type logContextModel struct {
+	InsertID       string            `json:"insertId"`
+	Timestamp      string            `json:"timestamp"`
+	LogName        string            `json:"logName"`
+	ResourceType   string            `json:"resourceType"`
+	ResourceLabels map[string]string `json:"resourceLabels"`
+	// Limit is the number of entries before and after the anchor
+	Limit int64 `json:"limit"`
+}
+
+// anchor validates the model and converts it to a cloudlogging.ContextAnchor
+func (m *logContextModel) anchor() (cloudlogging.ContextAnchor, int64, error) {
+	if m == nil || m.InsertID == "" {
+		return cloudlogging.ContextAnchor{}, 0, errors.New("missing required parameter: insertId")
+	}
+	if m.Timestamp == "" {
+		return cloudlogging.ContextAnchor{}, 0, errors.New("missing required parameter: timestamp")
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, m.Timestamp)
+	if err != nil {
+		return cloudlogging.ContextAnchor{}, 0, fmt.Errorf("invalid timestamp %q: expected RFC3339 format", m.Timestamp)
+	}
+	// Keys are written into the filter as they are, so each one has to be a
+	// single field name
+	for key := range m.ResourceLabels {
+		if err := cloudlogging.ValidateFieldPath("resource.labels." + key); err != nil {
+			return cloudlogging.ContextAnchor{}, 0, fmt.Errorf("invalid resource label %q", key)
+		}
+	}
+
+	limit := m.Limit
+	if limit <= 0 {
+		limit = defaultContextLimit
+	}
+	if limit > maxContextLimit {
+		limit = maxContextLimit
+	}
+
+	return cloudlogging.ContextAnchor{
+		InsertID:       m.InsertID,
+		Timestamp:      timestamp,
+		LogName:        m.LogName,
+		ResourceType:   m.ResourceType,
+		ResourceLabels: m.ResourceLabels,
+	}, limit, nil
+}
+
+// contextAnchorFromParams reads the anchor entry of the logContext resource
+// call from its URL parameters. Resource labels are passed as
+// `resource.labels.<key>=<value>` parameters.
+func contextAnchorFromParams(params url.Values) (cloudlogging.ContextAnchor, int64, error) {
+	model := logContextModel{
+		InsertID:       params.Get("InsertId"),
+		Timestamp:      params.Get("Timestamp"),
+		LogName:        params.Get("LogName"),
+		ResourceType:   params.Get("ResourceType"),
+		ResourceLabels: map[string]string{},
+	}
+	for k := range params {
+		if key, ok := strings.CutPrefix(k, "resource.labels."); ok && key != "" {
+			model.ResourceLabels[key] = params.Get(k)
+		}
+	}
+	if limit := params.Get("Limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return cloudlogging.ContextAnchor{}, 0, fmt.Errorf("invalid limit %q", limit)
+		}
+		model.Limit = l
+	}
+	return model.anchor()
+}
+
+// fetchLogContext returns the entries logged before and after the anchor
+// entry, both in ascending timestamp order
+func fetchLogContext(ctx context.Context, client cloudlogging.API, base cloudlogging.Query, anchor cloudlogging.ContextAnchor, limit int64) ([]*loggingpb.LogEntry, []*loggingpb.LogEntry, error) {
+	beforeQuery, afterQuery := cloudlogging.ContextQueries(base, anchor, limit)
+
+	before, err := client.ListLogs(ctx, beforeQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list entries before: %w", err)
+	}
+	after, err := client.ListLogs(ctx, afterQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list entries after: %w", err)
+	}
+
+	// The closest entries before the anchor are fetched first
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+	return before, after, nil
+}
+
+// logContextQuery handles the logContext query type, returning the entries
+// around the anchor entry in ascending timestamp order
+func (d *CloudLoggingDatasource) logContextQuery(ctx context.Context, q queryModel, client cloudlogging.API) backend.DataResponse {
+	response := backend.DataResponse{}
+
+	anchor, limit, err := q.Context.anchor()
+	if err != nil {
+		response.Error = fmt.Errorf("log context: %w", err)
+		return response
+	}
+
+	base := cloudlogging.Query{
+		ProjectID:     q.ProjectID,
+		BucketId:      q.BucketId,
+		ViewId:        q.ViewId,
+		ResourceNames: q.resourceNames,
+	}
+	before, after, err := fetchLogContext(ctx, client, base, anchor, limit)
+	if err != nil {
+		response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
+		return response
+	}
+
+	entries := append(before, after...)
+	redacted := d.redactor.RedactEntries(entries)
+	response.Frames = append(response.Frames, logFrames(entries)...)
+	if redacted > 0 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf(redactionNotice, redacted))
+	}
+	return response
+}
+
+// logContextResponse is the body of the logContext resource call
+type logContextResponse struct {
+	Before []logEntryResult `json:"before"`
+	After  []logEntryResult `json:"after"`
+}
+
+// logEntryResult is the JSON representation of a log entry in resource call responses
+type logEntryResult struct {
+	ID        string      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Message   string      `json:"message"`
+	Labels    data.Labels `json:"labels"`
+}
+
+func logEntryResults(entries []*loggingpb.LogEntry) []logEntryResult {
+	results := make([]logEntryResult, 0, len(entries))
+	for _, entry := range entries {
+		message, err := cloudlogging.GetLogEntryMessage(entry)
+		if err != nil {
+			log.DefaultLogger.Warn("failed getting log message", "warning", err)
+		}
+		results = append(results, logEntryResult{
+			ID:        entry.GetInsertId(),
+			Timestamp: entry.GetTimestamp().AsTime(),
+			Message:   message,
+			Labels:    cloudlogging.GetLogLabels(entry),
+		})
+	}
+	return results
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultMaxResponseBytes is the approximate size of the log frames of a
+// query after which they're truncated, unless the data source sets another.
+// It keeps responses under the size of the messages Grafana accepts from
+// plugins.
+const defaultMaxResponseBytes = 16 << 20
+
+// errBudgetExceeded stops reading entries once the frames are as large as
+// the byte budget allows
+var errBudgetExceeded = errors.New("response byte budget exceeded")
+
+// logFrameBuilder converts log entries into log frames as they're read, page
+// by page, so that entries don't need to be held until all of them are read
+type logFrameBuilder struct {
+	// budget is the approximate size the frames can reach
+	budget   int64
+	redactor *cloudlogging.Redactor
+	// dedup removes duplicate entries across pages, if set
+	dedup *cloudlogging.Deduplicator
+
+	frames data.Frames
+	size   int64
+	// read is the number of entries read, including those not converted
+	read       int
+	redacted   int
+	duplicates int
+	// truncated is set once entries were left out to stay within the budget
+	truncated bool
+}
+
+// newLogFrameBuilder creates a logFrameBuilder within the data source's byte
+// budget
+func (d *CloudLoggingDatasource) newLogFrameBuilder(dedup bool) *logFrameBuilder {
+	b := &logFrameBuilder{
+		budget:   d.maxResponseBytes,
+		redactor: d.redactor,
+		frames:   data.Frames{},
+	}
+	if b.budget <= 0 {
+		b.budget = defaultMaxResponseBytes
+	}
+	if dedup {
+		b.dedup = cloudlogging.NewDeduplicator()
+	}
+	return b
+}
+
+// addPage converts a page of entries into frames, removing duplicates
+// before merging split entries if mergeSplit is set, so that duplicated parts
+// aren't merged together. It returns errBudgetExceeded once the frames reach
+// the budget, leaving out the entry that didn't fit and any later one.
+func (b *logFrameBuilder) addPage(entries []*loggingpb.LogEntry, mergeSplit bool) error {
+	if b.dedup != nil {
+		var duplicates int
+		entries, duplicates = b.dedup.Dedup(entries)
+		b.duplicates += duplicates
+	}
+	if mergeSplit {
+		entries = cloudlogging.MergeSplitEntries(entries)
+	}
+	b.redacted += b.redactor.RedactEntries(entries)
+
+	for _, frame := range logFrames(entries) {
+		size := frameBytes(frame)
+		if b.size+size > b.budget {
+			b.truncated = true
+			return errBudgetExceeded
+		}
+		b.size += size
+		b.frames = append(b.frames, frame)
+	}
+	return nil
+}
+
+// readLogFrames reads the entries of a logs query into a frame builder: page
+// by page as they're received if the query reads several pages, else all at
+// once, as needed to sample them or merge split entries. Reading stops
+// without an error once the frames reach the budget.
+func readLogFrames(ctx context.Context, client cloudlogging.API, q *cloudlogging.Query, timeRange backend.TimeRange, windows int, mergeSplit bool, b *logFrameBuilder) error {
+	var err error
+	if windows <= 1 && !mergeSplit && q.Limit > cloudlogging.MaxPageSize {
+		err = client.ListLogPages(ctx, q, func(entries []*loggingpb.LogEntry) error {
+			b.read += len(entries)
+			return b.addPage(entries, false)
+		})
+	} else {
+		var entries []*loggingpb.LogEntry
+		if windows > 1 {
+			entries, err = sampleLogs(ctx, client, *q, timeRange, windows)
+		} else {
+			entries, err = client.ListLogs(ctx, q)
+		}
+		if err == nil {
+			b.read += len(entries)
+			err = b.addPage(entries, mergeSplit)
+		}
+	}
+	if errors.Is(err, errBudgetExceeded) {
+		return nil
+	}
+	return err
+}
+
+// frameBytes estimates the size of a frame once encoded, from the size of
+// its names, labels and values
+func frameBytes(frame *data.Frame) int64 {
+	size := int64(len(frame.Name))
+	for _, field := range frame.Fields {
+		size += int64(len(field.Name))
+		for k, v := range field.Labels {
+			size += int64(len(k) + len(v))
+		}
+		for i := 0; i < field.Len(); i++ {
+			switch v := field.At(i).(type) {
+			case string:
+				size += int64(len(v))
+			case *string:
+				if v != nil {
+					size += int64(len(*v))
+				}
+			default:
+				// Times and numbers
+				size += 8
+			}
+		}
+	}
+	return size
+}
+
+// formatBytes formats a number of bytes in the largest binary unit
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
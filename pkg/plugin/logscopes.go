@@ -0,0 +1,38 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+)
+
+// logScopeResources expands a log scope of a project into the names of the
+// projects and views it groups, checking that each of them can be queried
+func (d *CloudLoggingDatasource) logScopeResources(ctx context.Context, client cloudlogging.API, projectID string, scopeID string) ([]string, error) {
+	scope, err := client.GetLogScope(ctx, projectID, scopeID)
+	if err != nil {
+		return nil, fmt.Errorf("get log scope: %s", sanitizeErrorMessage(err))
+	}
+	if len(scope.ResourceNames) == 0 {
+		return nil, fmt.Errorf("log scope %q has no resources", scope.ID)
+	}
+	if err := d.access.checkLogScope(scope); err != nil {
+		return nil, err
+	}
+	return scope.ResourceNames, nil
+}
@@ -68,21 +68,35 @@ func (_m *API) ListLogs(_a0 context.Context, _a1 *cloudlogging.Query) ([]*loggin
 	return r0, r1
 }
 
+// ListLogPages provides a mock function with given fields: ctx, q, fn
+func (_m *API) ListLogPages(ctx context.Context, q *cloudlogging.Query, fn func([]*logging.LogEntry) error) error {
+	ret := _m.Called(ctx, q, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *cloudlogging.Query, func([]*logging.LogEntry) error) error); ok {
+		r0 = rf(ctx, q, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ListProjects provides a mock function with given fields: _a0, query
-func (_m *API) ListProjects(_a0 context.Context, query string) ([]string, error) {
+func (_m *API) ListProjects(_a0 context.Context, query cloudlogging.ProjectQuery) (*cloudlogging.ProjectPage, error) {
 	ret := _m.Called(_a0, query)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+	var r0 *cloudlogging.ProjectPage
+	if rf, ok := ret.Get(0).(func(context.Context, cloudlogging.ProjectQuery) *cloudlogging.ProjectPage); ok {
 		r0 = rf(_a0, query)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).(*cloudlogging.ProjectPage)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, cloudlogging.ProjectQuery) error); ok {
 		r1 = rf(_a0, query)
 	} else {
 		r1 = ret.Error(1)
@@ -91,13 +105,59 @@ func (_m *API) ListProjects(_a0 context.Context, query string) ([]string, error)
 	return r0, r1
 }
 
-// ListProjectBuckets provides a mock function with given fields: ctx, projectID
-func (_m *API) ListProjectBuckets(ctx context.Context, projectID string) ([]string, error) {
-	ret := _m.Called(ctx, projectID)
+// ListProjectBuckets provides a mock function with given fields: ctx, projectId
+func (_m *API) ListProjectBuckets(ctx context.Context, projectId string) ([]cloudlogging.LogBucket, error) {
+	ret := _m.Called(ctx, projectId)
+
+	var r0 []cloudlogging.LogBucket
+	if rf, ok := ret.Get(0).(func(context.Context, string) []cloudlogging.LogBucket); ok {
+		r0 = rf(ctx, projectId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cloudlogging.LogBucket)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, projectId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListProjectBucketViews provides a mock function with given fields: ctx, projectId, bucketId
+func (_m *API) ListProjectBucketViews(ctx context.Context, projectId string, bucketId string) ([]cloudlogging.LogView, error) {
+	ret := _m.Called(ctx, projectId, bucketId)
+
+	var r0 []cloudlogging.LogView
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []cloudlogging.LogView); ok {
+		r0 = rf(ctx, projectId, bucketId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cloudlogging.LogView)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, projectId, bucketId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLogNames provides a mock function with given fields: ctx, projectId, bucketId, viewId
+func (_m *API) ListLogNames(ctx context.Context, projectId string, bucketId string, viewId string) ([]string, error) {
+	ret := _m.Called(ctx, projectId, bucketId, viewId)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) []string); ok {
+		r0 = rf(ctx, projectId, bucketId, viewId)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]string)
@@ -105,8 +165,8 @@ func (_m *API) ListProjectBuckets(ctx context.Context, projectID string) ([]stri
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, projectId, bucketId, viewId)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -114,9 +174,9 @@ func (_m *API) ListProjectBuckets(ctx context.Context, projectID string) ([]stri
 	return r0, r1
 }
 
-// ListProjectBucketViews provides a mock function with given fields: ctx, bucketID
-func (_m *API) ListProjectBucketViews(ctx context.Context, projectId string, bucketID string) ([]string, error) {
-	ret := _m.Called(ctx, projectId, bucketID)
+// ListMonitoredResourceTypes provides a mock function with given fields: ctx
+func (_m *API) ListMonitoredResourceTypes(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
 
 	var r0 []string
 	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
@@ -137,6 +197,52 @@ func (_m *API) ListProjectBucketViews(ctx context.Context, projectId string, buc
 	return r0, r1
 }
 
+// ListLogScopes provides a mock function with given fields: ctx, projectId
+func (_m *API) ListLogScopes(ctx context.Context, projectId string) ([]cloudlogging.LogScope, error) {
+	ret := _m.Called(ctx, projectId)
+
+	var r0 []cloudlogging.LogScope
+	if rf, ok := ret.Get(0).(func(context.Context, string) []cloudlogging.LogScope); ok {
+		r0 = rf(ctx, projectId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cloudlogging.LogScope)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, projectId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLogScope provides a mock function with given fields: ctx, projectId, scopeId
+func (_m *API) GetLogScope(ctx context.Context, projectId string, scopeId string) (*cloudlogging.LogScope, error) {
+	ret := _m.Called(ctx, projectId, scopeId)
+
+	var r0 *cloudlogging.LogScope
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *cloudlogging.LogScope); ok {
+		r0 = rf(ctx, projectId, scopeId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*cloudlogging.LogScope)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, projectId, scopeId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // TestConnection provides a mock function with given fields: ctx, projectID
 func (_m *API) TestConnection(ctx context.Context, projectID string) error {
 	ret := _m.Called(ctx, projectID)
@@ -151,6 +257,43 @@ func (_m *API) TestConnection(ctx context.Context, projectID string) error {
 	return r0
 }
 
+// TestPermissions provides a mock function with given fields: ctx, projectID, permissions
+func (_m *API) TestPermissions(ctx context.Context, projectID string, permissions []string) ([]string, error) {
+	ret := _m.Called(ctx, projectID, permissions)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) []string); ok {
+		r0 = rf(ctx, projectID, permissions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(ctx, projectID, permissions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TestToken provides a mock function with given fields: ctx
+func (_m *API) TestToken(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewAPI interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// patternsFrame builds a table frame with one row per log pattern. Each row's
+// sparkline is a JSON array of the pattern's counts over the query time range,
+// split into equally sized buckets.
+func patternsFrame(patterns []*cloudlogging.Pattern, total int, timeRange backend.TimeRange) *data.Frame {
+	frame := data.NewFrame("patterns",
+		data.NewField("pattern", nil, []string{}),
+		data.NewField("count", nil, []int64{}),
+		data.NewField("sparkline", nil, []json.RawMessage{}),
+		data.NewField("sampleIds", nil, []string{}),
+	)
+	for _, p := range patterns {
+		sparkline, _ := json.Marshal(p.Sparkline)
+		frame.AppendRow(p.Pattern, p.Count, json.RawMessage(sparkline), strings.Join(p.SampleIDs, ","))
+	}
+
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		Custom: map[string]interface{}{
+			"from": timeRange.From,
+			"to":   timeRange.To,
+		},
+	}
+	if total > len(patterns) {
+		frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("Showing the %d most frequent of %d patterns", len(patterns), total),
+		})
+	}
+	return frame
+}
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"time"
 
+	"cloud.google.com/go/logging/apiv2/loggingpb"
 	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
 	"github.com/grafana/grafana-google-sdk-go/pkg/utils"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -51,6 +52,17 @@ const (
 	oauthpassthroughAuthentication = "oauthPassthrough"
 )
 
+// Query types, set by the query editor in DataQuery.QueryType. Queries without
+// a query type return log entries.
+const (
+	errorGroupsQueryType = "errorGroups"
+	patternsQueryType    = "patterns"
+	logContextQueryType  = "logContext"
+	fieldValuesQueryType = "fieldValues"
+	annotationsQueryType = "annotations"
+	traceLogsQueryType   = "traceLogs"
+)
+
 // config is the fields parsed from the front end
 type config struct {
 	AuthType                    string `json:"authenticationType"`
@@ -61,6 +73,24 @@ type config struct {
 	UsingImpersonation          bool   `json:"usingImpersonation"`
 	OAuthPassThru               bool   `json:"oauthPassThru"`
 	UniverseDomain              string `json:"universeDomain"`
+	// ProjectListFilter and LogBucketFilter restrict the projects and log
+	// buckets that can be queried, see newAccessFilter
+	ProjectListFilter string `json:"projectListFilter"`
+	LogBucketFilter   string `json:"logBucketFilter"`
+	// RestrictionFilter is ANDed into the filter of every query, limiting the
+	// entries users of the data source can read
+	RestrictionFilter string `json:"restrictionFilter"`
+	// RedactFields, RedactPatterns and RedactPresets remove sensitive values
+	// from results, see newRedactor
+	RedactFields   string   `json:"redactFields"`
+	RedactPatterns string   `json:"redactPatterns"`
+	RedactPresets  []string `json:"redactPresets"`
+	// MaxEntries is the maximum number of entries a query can ask for,
+	// defaultMaxEntries if not set
+	MaxEntries int64 `json:"maxEntries"`
+	// MaxResponseBytes is the approximate size of the log frames of a query
+	// after which they're truncated, defaultMaxResponseBytes if not set
+	MaxResponseBytes int64 `json:"maxResponseBytes"`
 }
 
 // toServiceAccountJSON creates the serviceAccountJSON bytes from the config fields
@@ -96,6 +126,19 @@ func NewCloudLoggingDatasource(ctx context.Context, settings backend.DataSourceI
 	if conf.AuthType == "" {
 		conf.AuthType = jwtAuthentication
 	}
+	if err := cloudlogging.ValidateFilter(conf.RestrictionFilter); err != nil {
+		return nil, fmt.Errorf("invalid restriction filter: %w", err)
+	}
+	redactor, err := newRedactor(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.MaxEntries < 0 {
+		return nil, fmt.Errorf("invalid max entries %d: must be a positive number", conf.MaxEntries)
+	}
+	if conf.MaxResponseBytes < 0 {
+		return nil, fmt.Errorf("invalid max response bytes %d: must be a positive number", conf.MaxResponseBytes)
+	}
 
 	// Only auto-switch to accessToken if the auth type is jwt (the default) and
 	// no JWT private key was provided. This preserves backward compat for
@@ -158,6 +201,11 @@ func NewCloudLoggingDatasource(ctx context.Context, settings backend.DataSourceI
 		client:           client,
 		oauthPassThrough: oauthPassThrough,
 		universeDomain:   conf.UniverseDomain,
+		access:           newAccessFilter(conf.ProjectListFilter, conf.LogBucketFilter),
+		restriction:      strings.TrimSpace(conf.RestrictionFilter),
+		redactor:         redactor,
+		maxEntries:       conf.MaxEntries,
+		maxResponseBytes: conf.MaxResponseBytes,
 	}, nil
 }
 
@@ -167,6 +215,20 @@ type CloudLoggingDatasource struct {
 	client           cloudlogging.API
 	oauthPassThrough bool
 	universeDomain   string
+	// access restricts the projects and log buckets that can be queried
+	access accessFilter
+	// restriction is ANDed into the filter of every query
+	restriction string
+	// redactor removes sensitive values from entries before they're returned
+	redactor *cloudlogging.Redactor
+	// maxEntries is the maximum number of entries a query can ask for
+	maxEntries int64
+	// maxResponseBytes is the byte budget of the log frames of a query
+	maxResponseBytes int64
+	// fieldsCache holds the fields sampled by the fields resource call
+	fieldsCache ttlCache[[]cloudlogging.FieldSummary]
+	// fieldValuesCache holds the results of fieldValues queries
+	fieldValuesCache ttlCache[fieldValuesResult]
 }
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
@@ -188,6 +250,19 @@ func jsonErrorBody(msg string) []byte {
 	return b
 }
 
+// filterValidationResponse is the body of the validateFilter resource call
+type filterValidationResponse struct {
+	Valid bool               `json:"valid"`
+	Error *filterSyntaxError `json:"error,omitempty"`
+}
+
+// filterSyntaxError locates a syntax error for the query editor
+type filterSyntaxError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
 // CallResource fetches some resource from GCP using the data source's credentials
 // Currently limited resources are fetched, other requests receive a 404
 func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
@@ -214,6 +289,7 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 		client = oauthClient
 		defer client.Close()
 	}
+	client = restrict(client, d.restriction)
 
 	var body []byte
 
@@ -222,6 +298,13 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 	//`/projects`
 	//`/logBuckets`
 	//`/logViews`
+	//`/validateFilter`
+	//`/logContext`
+	//`/fields`
+	//`/logNames`
+	//`/resourceTypes`
+	//`/export`
+	//`/logScopes`
 	resource := strings.ToLower(req.Path)
 
 	if resource == "gcedefaultproject" {
@@ -248,9 +331,15 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 				Body:   jsonErrorBody("Invalid request URL"),
 			})
 		}
-		searchQuery := reqUrl.Query().Get("query")
+		projectQuery, err := projectQueryFromParams(reqUrl.Query())
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
 
-		projects, err := client.ListProjects(ctx, searchQuery)
+		projects, err := d.listAllowedProjects(ctx, client, projectQuery)
 		if err != nil {
 			log.DefaultLogger.Error("problem listing projects", "error", err)
 			return sender.Send(&backend.CallResourceResponse{
@@ -282,8 +371,14 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 				Body:   jsonErrorBody("Missing required parameter: ProjectId"),
 			})
 		}
+		if !d.access.allowProject(params.Get("ProjectId")) {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(fmt.Sprintf("%s: project %q is not allowed by this data source", errAccessDenied, params.Get("ProjectId"))),
+			})
+		}
 
-		bucketNames, err := client.ListProjectBuckets(ctx, params.Get("ProjectId"))
+		buckets, err := client.ListProjectBuckets(ctx, params.Get("ProjectId"))
 		if err != nil {
 			log.DefaultLogger.Error("problem listing log buckets", "error", err)
 			return sender.Send(&backend.CallResourceResponse{
@@ -292,7 +387,7 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 			})
 		}
 
-		body, err = json.Marshal(bucketNames)
+		body, err = json.Marshal(d.access.filterBuckets(buckets))
 		if err != nil {
 			return sender.Send(&backend.CallResourceResponse{
 				Status: http.StatusInternalServerError,
@@ -321,6 +416,12 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 				Body:   jsonErrorBody("Missing required parameter: BucketId"),
 			})
 		}
+		if err := d.access.check(params.Get("ProjectId"), params.Get("BucketId")); err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
 
 		views, err := client.ListProjectBucketViews(ctx, params.Get("ProjectId"), params.Get("BucketId"))
 		if err != nil {
@@ -338,6 +439,265 @@ func (d *CloudLoggingDatasource) CallResource(ctx context.Context, req *backend.
 				Body:   jsonErrorBody("Unable to create response"),
 			})
 		}
+	} else if resource == "validatefilter" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+
+		result := filterValidationResponse{Valid: true}
+		var syntaxErr *cloudlogging.FilterSyntaxError
+		if err := cloudlogging.ValidateFilter(reqUrl.Query().Get("filter")); errors.As(err, &syntaxErr) {
+			result = filterValidationResponse{
+				Error: &filterSyntaxError{
+					Line:    syntaxErr.Line,
+					Column:  syntaxErr.Column,
+					Message: syntaxErr.Message,
+				},
+			}
+		}
+
+		body, err = json.Marshal(result)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "logcontext" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+		params, _ := url.ParseQuery(reqUrl.RawQuery)
+
+		if params.Get("ProjectId") == "" {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Missing required parameter: ProjectId"),
+			})
+		}
+		if err := d.access.check(params.Get("ProjectId"), params.Get("BucketId")); err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+		anchor, limit, err := contextAnchorFromParams(params)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+
+		base := cloudlogging.Query{
+			ProjectID: params.Get("ProjectId"),
+			BucketId:  params.Get("BucketId"),
+			ViewId:    params.Get("ViewId"),
+		}
+		before, after, err := fetchLogContext(ctx, client, base, anchor, limit)
+		if err != nil {
+			log.DefaultLogger.Error("problem fetching log context", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+			})
+		}
+		d.redactor.RedactEntries(before)
+		d.redactor.RedactEntries(after)
+
+		body, err = json.Marshal(logContextResponse{
+			Before: logEntryResults(before),
+			After:  logEntryResults(after),
+		})
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "fields" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+		params, _ := url.ParseQuery(reqUrl.RawQuery)
+
+		if params.Get("ProjectId") == "" {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Missing required parameter: ProjectId"),
+			})
+		}
+		if err := d.access.check(params.Get("ProjectId"), params.Get("BucketId")); err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+		limit, err := fieldValuesLimit(params)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+
+		fields, err := d.listFields(ctx, client, params.Get("ProjectId"), params.Get("BucketId"), params.Get("ViewId"), limit, !d.oauthPassThrough)
+		if err != nil {
+			log.DefaultLogger.Error("problem sampling log fields", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+			})
+		}
+
+		body, err = json.Marshal(fields)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "lognames" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+		params, _ := url.ParseQuery(reqUrl.RawQuery)
+
+		if params.Get("ProjectId") == "" {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Missing required parameter: ProjectId"),
+			})
+		}
+		if err := d.access.check(params.Get("ProjectId"), params.Get("BucketId")); err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+
+		logNames, err := client.ListLogNames(ctx, params.Get("ProjectId"), params.Get("BucketId"), params.Get("ViewId"))
+		if err != nil {
+			log.DefaultLogger.Error("problem listing log names", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+			})
+		}
+
+		body, err = json.Marshal(logNames)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "resourcetypes" {
+		resourceTypes, err := client.ListMonitoredResourceTypes(ctx)
+		if err != nil {
+			log.DefaultLogger.Error("problem listing monitored resource types", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+			})
+		}
+
+		body, err = json.Marshal(resourceTypes)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "logscopes" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+		params, _ := url.ParseQuery(reqUrl.RawQuery)
+
+		if params.Get("ProjectId") == "" {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Missing required parameter: ProjectId"),
+			})
+		}
+		if !d.access.allowProject(params.Get("ProjectId")) {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(fmt.Sprintf("%s: project %q is not allowed by this data source", errAccessDenied, params.Get("ProjectId"))),
+			})
+		}
+
+		scopes, err := client.ListLogScopes(ctx, params.Get("ProjectId"))
+		if err != nil {
+			log.DefaultLogger.Error("problem listing log scopes", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadGateway,
+				Body:   jsonErrorBody(sanitizeErrorMessage(err)),
+			})
+		}
+
+		body, err = json.Marshal(d.access.filterLogScopes(scopes))
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusInternalServerError,
+				Body:   jsonErrorBody("Unable to create response"),
+			})
+		}
+	} else if resource == "export" {
+		reqUrl, err := url.Parse(req.URL)
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody("Invalid request URL"),
+			})
+		}
+		exportReq, err := exportRequestFromParams(reqUrl.Query())
+		if err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+		if err := d.access.check(exportReq.query.ProjectID, exportReq.query.BucketId); err != nil {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusForbidden,
+				Body:   jsonErrorBody(err.Error()),
+			})
+		}
+		if exportReq.logScope != "" {
+			resourceNames, err := d.logScopeResources(ctx, client, exportReq.query.ProjectID, exportReq.logScope)
+			if err != nil {
+				return sender.Send(&backend.CallResourceResponse{
+					Status: http.StatusForbidden,
+					Body:   jsonErrorBody(err.Error()),
+				})
+			}
+			exportReq.query.ResourceNames = resourceNames
+		}
+
+		// The response is sent in chunks as entries are read
+		return d.exportLogs(ctx, client, exportReq, sender)
 	} else {
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusNotFound,
@@ -373,6 +733,7 @@ func (d *CloudLoggingDatasource) QueryData(ctx context.Context, req *backend.Que
 		client = oauthClient
 		defer client.Close()
 	}
+	client = restrict(client, d.restriction)
 
 	// create response struct
 	response := backend.NewQueryDataResponse()
@@ -396,6 +757,47 @@ type queryModel struct {
 	ProjectID string `json:"projectId"`
 	BucketId  string `json:"bucketId"`
 	ViewId    string `json:"viewId"`
+	// GroupSplitEntries reassembles entries that Cloud Logging split into parts
+	GroupSplitEntries bool `json:"groupSplitEntries,omitempty"`
+	// DedupEntries removes entries returned more than once, as by a log scope
+	// including both a project and a bucket its logs are routed to
+	DedupEntries bool `json:"dedupEntries,omitempty"`
+	// PatternSimilarity and MaxPatterns tune the patterns query type
+	PatternSimilarity float64 `json:"patternSimilarity,omitempty"`
+	MaxPatterns       int     `json:"maxPatterns,omitempty"`
+	// Order is the timestamp sort order of the entries, "desc" (default) or "asc"
+	Order string `json:"order,omitempty"`
+	// Context is the anchor entry of the logContext query type
+	Context *logContextModel `json:"context,omitempty"`
+	// FieldPath and MaxValues are the field whose distinct values the
+	// fieldValues query type returns, and how many values it returns
+	FieldPath string `json:"fieldPath,omitempty"`
+	MaxValues int    `json:"maxValues,omitempty"`
+	// Annotation maps entry fields to annotations in the annotations query type
+	Annotation *annotationModel `json:"annotation,omitempty"`
+	// LogScope is the ID of a log scope of the project to query instead of
+	// the bucket and view
+	LogScope string `json:"logScope,omitempty"`
+	// TimeShift moves the dashboard's time range back, such as `1d` to
+	// compare with the day before, and TimeRange replaces it altogether
+	TimeShift string          `json:"timeShift,omitempty"`
+	TimeRange *timeRangeModel `json:"timeRange,omitempty"`
+	// AlignTimestamps moves the timestamps of a shifted or replaced time
+	// range so they overlay the dashboard's time range
+	AlignTimestamps bool `json:"alignTimestamps,omitempty"`
+	// Limit is the number of entries to read, instead of the number of data
+	// points Grafana asks for, up to the data source's maximum
+	Limit int64 `json:"limit,omitempty"`
+	// SampleWindows splits the time range into windows read with an even
+	// share of the limit each, so entries are spread over the time range
+	SampleWindows int `json:"sampleWindows,omitempty"`
+	// TraceID and SpanID select the entries of a trace, or of one of its
+	// spans, in the traceLogs query type
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+
+	// resourceNames are the resources of the log scope, if any
+	resourceNames []string
 }
 
 func (d *CloudLoggingDatasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, client cloudlogging.API) backend.DataResponse {
@@ -407,34 +809,162 @@ func (d *CloudLoggingDatasource) query(ctx context.Context, pCtx backend.PluginC
 		return response
 	}
 
+	if err := d.access.check(q.ProjectID, q.BucketId); err != nil {
+		response.Error = err
+		return response
+	}
+	if q.LogScope != "" {
+		resourceNames, err := d.logScopeResources(ctx, client, q.ProjectID, q.LogScope)
+		if err != nil {
+			response.Error = err
+			return response
+		}
+		q.resourceNames = resourceNames
+	}
+
+	if query.QueryType == logContextQueryType {
+		return d.logContextQuery(ctx, q, client)
+	}
+
+	order := strings.ToLower(q.Order)
+	if order != "" && order != cloudlogging.OrderDescending && order != cloudlogging.OrderAscending {
+		response.Error = fmt.Errorf("invalid order %q: must be %q or %q", q.Order, cloudlogging.OrderDescending, cloudlogging.OrderAscending)
+		return response
+	}
+
 	var qstr string
 	if q.QueryText != "" {
 		qstr = q.QueryText
 	} else if q.Query != "" {
 		qstr = q.Query
 	}
+	if err := cloudlogging.ValidateFilter(qstr); err != nil {
+		response.Error = fmt.Errorf("invalid query: %w", err)
+		return response
+	}
+	timeRange, err := queryTimeRange(q, query.TimeRange)
+	if err != nil {
+		response.Error = err
+		return response
+	}
+	limit, explicitLimit, err := d.entryLimit(q, query.MaxDataPoints)
+	if err != nil {
+		response.Error = err
+		return response
+	}
+	if err := validateSampleWindows(q.SampleWindows); err != nil {
+		response.Error = err
+		return response
+	}
+	// Each window reads at least one entry
+	windows := q.SampleWindows
+	if int64(windows) > limit {
+		windows = int(limit)
+	}
+	if query.QueryType == traceLogsQueryType {
+		return d.traceLogsQuery(ctx, q, qstr, timeRange, limit, explicitLimit, client)
+	}
+	if query.QueryType == fieldValuesQueryType {
+		return d.fieldValuesQuery(ctx, q, qstr, timeRange, client)
+	}
+	var annotationOpts cloudlogging.AnnotationOptions
+	if query.QueryType == annotationsQueryType {
+		if annotationOpts, err = annotationOptions(q.Annotation); err != nil {
+			response.Error = err
+			return response
+		}
+	}
+
 	clientRequest := cloudlogging.Query{
 		ProjectID: q.ProjectID,
 		BucketId:  q.BucketId,
 		ViewId:    q.ViewId,
 		Filter:    qstr,
-		Limit:     query.MaxDataPoints,
+		Limit:     limit,
 		TimeRange: struct {
 			From string
 			To   string
 		}{
-			From: query.TimeRange.From.Format(time.RFC3339),
-			To:   query.TimeRange.To.Format(time.RFC3339),
+			From: timeRange.From.Format(time.RFC3339),
+			To:   timeRange.To.Format(time.RFC3339),
 		},
+		Order:         order,
+		ResourceNames: q.resourceNames,
 	}
 
-	logs, err := client.ListLogs(ctx, &clientRequest)
-	if err != nil {
-		response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
-		return response
+	// Log frames are built as entries are read, within the byte budget
+	var read, duplicates, redacted int
+	aggregated := query.QueryType == errorGroupsQueryType || query.QueryType == patternsQueryType || query.QueryType == annotationsQueryType
+	if !aggregated {
+		b := d.newLogFrameBuilder(q.DedupEntries)
+		if err := readLogFrames(ctx, client, &clientRequest, timeRange, windows, q.GroupSplitEntries, b); err != nil {
+			response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
+			return response
+		}
+		response.Frames = b.frames
+		read, duplicates, redacted = b.read, b.duplicates, b.redacted
+		if b.truncated {
+			addNotice(response.Frames, data.NoticeSeverityWarning, fmt.Sprintf("Results were truncated to %d entries to keep the response under %s: narrow the query's time range or filter to see more",
+				len(b.frames), formatBytes(b.budget)))
+		}
+	} else {
+		var logs []*loggingpb.LogEntry
+		if windows > 1 {
+			logs, err = sampleLogs(ctx, client, clientRequest, timeRange, windows)
+		} else {
+			logs, err = client.ListLogs(ctx, &clientRequest)
+		}
+		if err != nil {
+			response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
+			return response
+		}
+		read = len(logs)
+		if q.DedupEntries {
+			logs, duplicates = cloudlogging.DedupEntries(logs)
+		}
+		if q.GroupSplitEntries {
+			logs = cloudlogging.MergeSplitEntries(logs)
+		}
+		redacted = d.redactor.RedactEntries(logs)
+
+		switch query.QueryType {
+		case errorGroupsQueryType:
+			response.Frames = append(response.Frames, errorGroupsFrame(cloudlogging.GroupErrors(logs)))
+		case patternsQueryType:
+			patterns, total := cloudlogging.DetectPatterns(logs, cloudlogging.PatternOptions{
+				Similarity:  q.PatternSimilarity,
+				MaxPatterns: q.MaxPatterns,
+				From:        timeRange.From,
+				To:          timeRange.To,
+			})
+			response.Frames = append(response.Frames, patternsFrame(patterns, total, timeRange))
+		case annotationsQueryType:
+			response.Frames = append(response.Frames, annotationsFrame(cloudlogging.BuildAnnotations(logs, annotationOpts)))
+		}
 	}
 
-	// create data frame response.
+	if redacted > 0 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf(redactionNotice, redacted))
+	}
+	if duplicates > 0 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf("%d duplicate entries were removed", duplicates))
+	}
+	if explicitLimit && int64(read) >= limit {
+		addNotice(response.Frames, data.NoticeSeverityWarning, fmt.Sprintf(truncationNotice, limit))
+	}
+	if windows > 1 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf("Entries were sampled from %d windows of %s each",
+			windows, (timeRange.To.Sub(timeRange.From)/time.Duration(windows)).Round(time.Second)))
+	}
+	if q.AlignTimestamps {
+		alignFrames(response.Frames, query.TimeRange.From.Sub(timeRange.From))
+	}
+
+	return response
+}
+
+// logFrames creates the data frames for log entries, one frame per entry
+func logFrames(logs []*loggingpb.LogEntry) []*data.Frame {
 	frames := []*data.Frame{}
 
 	for i := 0; i < len(logs); i++ {
@@ -456,12 +986,19 @@ func (d *CloudLoggingDatasource) query(ctx context.Context, pCtx backend.PluginC
 		frames = append(frames, f)
 	}
 
-	// add the frames to the response.
-	for _, f := range frames {
-		response.Frames = append(response.Frames, f)
-	}
+	return frames
+}
 
-	return response
+// addNotice adds a notice for users to the first frame, if any
+func addNotice(frames data.Frames, severity data.NoticeSeverity, text string) {
+	if len(frames) == 0 {
+		return
+	}
+	frame := frames[0]
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{Severity: severity, Text: text})
 }
 
 // CheckHealth handles health checks sent from Grafana to the plugin.
@@ -485,7 +1022,6 @@ func (d *CloudLoggingDatasource) CheckHealth(ctx context.Context, req *backend.C
 		defer client.Close()
 	}
 
-	var status = backend.HealthStatusOk
 	settings := req.PluginContext.DataSourceInstanceSettings
 
 	var conf config
@@ -512,17 +1048,8 @@ func (d *CloudLoggingDatasource) CheckHealth(ctx context.Context, req *backend.C
 			Message: "Please define a default project for OAuth authentication",
 		}, nil
 	}
-	if err := client.TestConnection(ctx, conf.DefaultProject); err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("failed to run test query: %s", sanitizeErrorMessage(err)),
-		}, nil
-	}
 
-	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: fmt.Sprintf("Successfully queried logs from GCP project %s", conf.DefaultProject),
-	}, nil
+	return diagnose(ctx, client, conf.DefaultProject), nil
 }
 
 // htmlLikePattern matches error strings that contain HTML responses. It targets
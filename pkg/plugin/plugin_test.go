@@ -18,6 +18,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -187,7 +192,7 @@ func TestQueryData_SingleLog(t *testing.T) {
 	require.Len(t, frame.Fields, 2)
 	require.Equal(t, data.VisTypeLogs, string(frame.Meta.PreferredVisualization))
 
-	expectedFrame := []byte(`{"schema":{"name":"b6f39be2-b298-44da-9001-1f04e5756fa0","meta":{"typeVersion":[0,0],"preferredVisualisationType":"logs"},"fields":[{"name":"time","type":"time","typeInfo":{"frame":"time.Time"}},{"name":"content","type":"string","typeInfo":{"frame":"string"},"labels":{"id":"b6f39be2-b298-44da-9001-1f04e5756fa0","labels.\"custom_label\"":"custom_value","labels.\"instance_id\"":"unique","level":"info","resource.type":"gce_instance","textPayload":"Full log message from this GCE instance","trace":"projects/xxx/traces/c0e331eab1515bbcd1b8306029902ff7","traceId":"c0e331eab1515bbcd1b8306029902ff7"}}]},"data":{"values":[[1660920349373],["Full log message from this GCE instance"]]}}`)
+	expectedFrame := []byte(`{"schema":{"name":"b6f39be2-b298-44da-9001-1f04e5756fa0","meta":{"typeVersion":[0,0],"preferredVisualisationType":"logs"},"fields":[{"name":"time","type":"time","typeInfo":{"frame":"time.Time"}},{"name":"content","type":"string","typeInfo":{"frame":"string"},"labels":{"id":"b6f39be2-b298-44da-9001-1f04e5756fa0","labels.\"custom_label\"":"custom_value","labels.\"instance_id\"":"unique","level":"info","logName":"organizations/1234567890/logs/cloudresourcemanager.googleapis.com%2Factivity","receiveTimestamp":"2022-08-19T14:45:49.373Z","resource.type":"gce_instance","textPayload":"Full log message from this GCE instance","trace":"projects/xxx/traces/c0e331eab1515bbcd1b8306029902ff7","traceId":"c0e331eab1515bbcd1b8306029902ff7"}}]},"data":{"values":[[1660920349373],["Full log message from this GCE instance"]]}}`)
 
 	serializedFrame, err := frame.MarshalJSON()
 	require.NoError(t, err)
@@ -375,10 +380,16 @@ func (s *responseSender) Send(resp *backend.CallResourceResponse) error {
 }
 
 func TestCallResource_Projects(t *testing.T) {
-	expectedProjects := []string{"project-a", "project-b", "project-c", "project-d", "project-e"}
+	expectedProjects := &cloudlogging.ProjectPage{
+		Projects: []cloudlogging.Project{
+			{ID: "project-a", DisplayName: "Project A", Number: "111", Parent: "organizations/1"},
+			{ID: "project-b", DisplayName: "Project B", Number: "222", Parent: "folders/2", Labels: map[string]string{"env": "prod"}},
+		},
+		NextPageToken: "next",
+	}
 
 	client := mocks.NewAPI(t)
-	client.On("ListProjects", mock.Anything, "").Return(expectedProjects, nil)
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{}).Return(expectedProjects, nil)
 
 	ds := &CloudLoggingDatasource{
 		client: client,
@@ -394,18 +405,26 @@ func TestCallResource_Projects(t *testing.T) {
 	require.NotNil(t, sender.resp)
 	require.Equal(t, 200, sender.resp.Status)
 
-	var projects []string
+	var projects cloudlogging.ProjectPage
 	err = json.Unmarshal(sender.resp.Body, &projects)
 	require.NoError(t, err)
-	require.Equal(t, expectedProjects, projects)
+	require.Equal(t, *expectedProjects, projects)
 	client.AssertExpectations(t)
 }
 
 func TestCallResource_ProjectsWithQuery(t *testing.T) {
-	expectedProjects := []string{"proj-a"}
+	expectedProjects := &cloudlogging.ProjectPage{
+		Projects: []cloudlogging.Project{{ID: "proj-a"}},
+	}
 
 	client := mocks.NewAPI(t)
-	client.On("ListProjects", mock.Anything, "proj-a").Return(expectedProjects, nil)
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{
+		Search:    "proj-a",
+		Labels:    map[string]string{"env": "prod", "team": ""},
+		Parent:    "folders/123",
+		PageSize:  50,
+		PageToken: "abc",
+	}).Return(expectedProjects, nil)
 
 	ds := &CloudLoggingDatasource{
 		client: client,
@@ -414,20 +433,311 @@ func TestCallResource_ProjectsWithQuery(t *testing.T) {
 	sender := &responseSender{}
 	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
 		Path: "projects",
-		URL:  "projects?query=proj-a",
+		URL:  "projects?query=proj-a&label=env:prod&label=team&parent=folders%2F123&pageSize=50&pageToken=abc",
 	}, sender)
 
 	require.NoError(t, err)
 	require.NotNil(t, sender.resp)
 	require.Equal(t, 200, sender.resp.Status)
 
-	var projects []string
+	var projects cloudlogging.ProjectPage
 	err = json.Unmarshal(sender.resp.Body, &projects)
 	require.NoError(t, err)
-	require.Equal(t, expectedProjects, projects)
+	require.Equal(t, *expectedProjects, projects)
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_ProjectsAccessFilter(t *testing.T) {
+	client := mocks.NewAPI(t)
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 3}).Return(&cloudlogging.ProjectPage{
+		Projects:      []cloudlogging.Project{{ID: "team-a-1"}, {ID: "team-b-1"}, {ID: "team-b-2"}},
+		NextPageToken: "2",
+	}, nil).Once()
+	// Pages thinned out by the filter are topped up from the next ones
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 2, PageToken: "2"}).Return(&cloudlogging.ProjectPage{
+		Projects:      []cloudlogging.Project{{ID: "team-b-3"}, {ID: "team-b-4"}},
+		NextPageToken: "3",
+	}, nil).Once()
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 2, PageToken: "3"}).Return(&cloudlogging.ProjectPage{
+		Projects:      []cloudlogging.Project{{ID: "team-a-2"}, {ID: "team-a-3"}},
+		NextPageToken: "4",
+	}, nil).Once()
+
+	ds := &CloudLoggingDatasource{
+		client: client,
+		access: newAccessFilter("team-a-.*", ""),
+	}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "projects",
+		URL:  "projects?pageSize=3",
+	}, sender)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, sender.resp.Status)
+
+	var projects cloudlogging.ProjectPage
+	require.NoError(t, json.Unmarshal(sender.resp.Body, &projects))
+	require.Equal(t, cloudlogging.ProjectPage{
+		Projects:      []cloudlogging.Project{{ID: "team-a-1"}, {ID: "team-a-2"}, {ID: "team-a-3"}},
+		NextPageToken: "4",
+	}, projects)
+}
+
+func TestCallResource_ProjectsInvalidParent(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := &CloudLoggingDatasource{
+		client: client,
+	}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "projects",
+		URL:  "projects?parent=folders%2F1%20OR%20id%3A*",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 400, sender.resp.Status)
+	require.Contains(t, string(sender.resp.Body), "invalid parent")
+}
+
+func TestAccessFilter(t *testing.T) {
+	f := newAccessFilter("team-a-.*\n\n  shared  \nbad[regex", "global/buckets/.*\n!global/buckets/secret-.*\n!\n")
+
+	require.True(t, f.allowProject("team-a-prod"))
+	require.True(t, f.allowProject("shared"))
+	require.True(t, f.allowProject("bad[regex"))
+	require.False(t, f.allowProject("team-b-prod"))
+	require.False(t, f.allowProject("xteam-a-prod"))
+
+	require.True(t, f.allowBucket("global/buckets/team"))
+	require.False(t, f.allowBucket("global/buckets/secret-1"))
+	require.False(t, f.allowBucket("us-east1/buckets/team"))
+	// The default resource reads the _Default and _Required buckets
+	require.True(t, f.allowBucket(""))
+	require.False(t, newAccessFilter("", "global/buckets/team-.*").allowBucket(""))
+
+	require.NoError(t, f.check("team-a-prod", "global/buckets/team"))
+	require.ErrorIs(t, f.check("team-b-prod", "global/buckets/team"), errAccessDenied)
+	require.ErrorIs(t, f.check("team-a-prod", "global/buckets/secret-1"), errAccessDenied)
+
+	require.Equal(t, []cloudlogging.LogBucket{{ID: "global/buckets/team"}}, f.filterBuckets([]cloudlogging.LogBucket{{ID: "global/buckets/team"}, {ID: "global/buckets/secret-1"}}))
+
+	// The zero value allows everything
+	require.NoError(t, accessFilter{}.check("any", ""))
+}
+
+func TestNewCloudLoggingDatasource_AccessFilters(t *testing.T) {
+	jsonData := `{"authenticationType": "oauthPassthrough", "projectListFilter": "allowed", "logBucketFilter": "!global/buckets/secret"}`
+	instance, err := NewCloudLoggingDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(jsonData),
+	})
+	require.NoError(t, err)
+
+	ds := instance.(*CloudLoggingDatasource)
+	require.True(t, ds.access.allowProject("allowed"))
+	require.False(t, ds.access.allowProject("other"))
+	require.True(t, ds.access.allowBucket(""))
+	require.False(t, ds.access.allowBucket("global/buckets/secret"))
+}
+
+func TestAccessFilter_ExcludedDefaultBucket(t *testing.T) {
+	f := newAccessFilter("", "!global/buckets/_Default")
+	require.True(t, f.allowBucket("global/buckets/team"))
+	require.False(t, f.allowBucket("global/buckets/_Default"))
+
+	// Clearing the bucket would read the _Default bucket anyway
+	require.False(t, f.allowBucket(""))
+	require.ErrorIs(t, f.check("any", ""), errAccessDenied)
+}
+
+func TestQueryData_AccessDenied(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+		access: newAccessFilter("allowed", ""),
+	}
+	refID := "test"
+	for _, queryType := range []string{"", logContextQueryType, fieldValuesQueryType} {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{
+					JSON:      []byte(`{"projectId": "other", "queryText": "severity >= ERROR"}`),
+					QueryType: queryType,
+					RefID:     refID,
+				},
+			},
+		})
+
+		require.NoError(t, err)
+		require.ErrorIs(t, resp.Responses[refID].Error, errAccessDenied)
+		require.ErrorContains(t, resp.Responses[refID].Error, `project "other"`)
+	}
+}
+
+func TestCallResource_AccessFilters(t *testing.T) {
+	client := mocks.NewAPI(t)
+	client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{}).Return(&cloudlogging.ProjectPage{
+		Projects: []cloudlogging.Project{{ID: "allowed"}, {ID: "other"}},
+	}, nil)
+	client.On("ListProjectBuckets", mock.Anything, "allowed").Return([]cloudlogging.LogBucket{
+		{ID: "global/buckets/public", Location: "global", RetentionDays: 30, LifecycleState: "ACTIVE"},
+		{ID: "global/buckets/secret", Location: "global", RetentionDays: 30, LifecycleState: "ACTIVE"},
+	}, nil)
+
+	ds := &CloudLoggingDatasource{
+		client: client,
+		access: newAccessFilter("allowed", "!global/buckets/secret"),
+	}
+	call := func(path string, url string) *backend.CallResourceResponse {
+		sender := &responseSender{}
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{Path: path, URL: url}, sender)
+		require.NoError(t, err)
+		require.NotNil(t, sender.resp)
+		return sender.resp
+	}
+
+	resp := call("projects", "projects")
+	require.Equal(t, 200, resp.Status)
+	var projects cloudlogging.ProjectPage
+	require.NoError(t, json.Unmarshal(resp.Body, &projects))
+	require.Equal(t, []cloudlogging.Project{{ID: "allowed"}}, projects.Projects)
+
+	resp = call("logBuckets", "logBuckets?ProjectId=allowed")
+	require.Equal(t, 200, resp.Status)
+	var buckets []cloudlogging.LogBucket
+	require.NoError(t, json.Unmarshal(resp.Body, &buckets))
+	require.Equal(t, []cloudlogging.LogBucket{
+		{ID: "global/buckets/public", Location: "global", RetentionDays: 30, LifecycleState: "ACTIVE"},
+	}, buckets)
+
+	for path, url := range map[string]string{
+		"logBuckets": "logBuckets?ProjectId=other",
+		"logViews":   "logViews?ProjectId=allowed&BucketId=global%2Fbuckets%2Fsecret",
+		"logNames":   "logNames?ProjectId=other",
+		"fields":     "fields?ProjectId=allowed&BucketId=global%2Fbuckets%2Fsecret",
+		"logContext": "logContext?ProjectId=other&InsertId=a&Timestamp=2024-03-01T12%3A00%3A00Z",
+	} {
+		resp = call(path, url)
+		require.Equal(t, 403, resp.Status, path)
+		require.Contains(t, string(resp.Body), "permission denied", path)
+	}
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_Restriction(t *testing.T) {
+	restriction := `resource.labels.namespace_name="team-a"`
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Restriction == restriction && q.Filter == "severity >= ERROR OR true"
+	})).Return([]*loggingpb.LogEntry{}, nil)
+
+	ds := CloudLoggingDatasource{
+		client:      client,
+		restriction: restriction,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:          []byte(`{"projectId": "testing", "queryText": "severity >= ERROR OR true"}`),
+				RefID:         refID,
+				MaxDataPoints: 20,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_Fields_Restriction(t *testing.T) {
+	restriction := `resource.labels.namespace_name="team-a"`
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Restriction == restriction
+	})).Return([]*loggingpb.LogEntry{}, nil)
+
+	ds := &CloudLoggingDatasource{
+		client:      client,
+		restriction: restriction,
+	}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "fields",
+		URL:  "fields?ProjectId=my-project",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 200, sender.resp.Status)
 	client.AssertExpectations(t)
 }
 
+func TestNewCloudLoggingDatasource_Restriction(t *testing.T) {
+	instance, err := NewCloudLoggingDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authenticationType": "oauthPassthrough", "restrictionFilter": " resource.labels.namespace_name=\"team-a\"\n"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, `resource.labels.namespace_name="team-a"`, instance.(*CloudLoggingDatasource).restriction)
+
+	_, err = NewCloudLoggingDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authenticationType": "oauthPassthrough", "restrictionFilter": "a=1) OR (b=2"}`),
+	})
+	require.ErrorContains(t, err, "invalid restriction filter")
+}
+
+func TestQueryData_Redaction(t *testing.T) {
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return([]*loggingpb.LogEntry{{
+		InsertId: "a",
+		Labels:   map[string]string{"user": "jane@example.com"},
+		Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: "login by jane@example.com from 10.1.2.3"},
+	}}, nil)
+
+	redactor, err := newRedactor(config{
+		RedactFields:  "\nlabels.user\n",
+		RedactPresets: []string{cloudlogging.RedactEmails, cloudlogging.RedactIPv4},
+	})
+	require.NoError(t, err)
+	ds := CloudLoggingDatasource{
+		client:   client,
+		redactor: redactor,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:          []byte(`{"projectId": "testing", "queryText": ""}`),
+				RefID:         refID,
+				MaxDataPoints: 20,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	require.Len(t, resp.Responses[refID].Frames, 1)
+
+	frame := resp.Responses[refID].Frames[0]
+	require.Equal(t, "login by [REDACTED] from [REDACTED]", frame.Fields[1].At(0))
+	require.Equal(t, "[REDACTED]", frame.Fields[1].Labels[`labels."user"`])
+	require.Equal(t, "2 values were redacted by the data source's redaction rules", frame.Meta.Notices[0].Text)
+}
+
+func TestNewCloudLoggingDatasource_InvalidRedactionPattern(t *testing.T) {
+	_, err := NewCloudLoggingDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authenticationType": "oauthPassthrough", "redactPatterns": "token=\\w+\n(unclosed"}`),
+	})
+	require.ErrorContains(t, err, "invalid redaction pattern")
+}
+
 func TestSanitizeErrorMessage_HTML(t *testing.T) {
 	htmlErr := errors.New(`<html><head> <meta http-equiv="content-type" content="text/html;charset=utf-8"> <title>502 Server Error</title> </head> <body text=#000000 bgcolor=#ffffff> <h1>Error: Server Error</h1> <h2>The server encountered a temporary error and could not complete your request.<p>Please try again in 30 seconds.</h2> <h2></h2> </body></html>`)
 	result := sanitizeErrorMessage(htmlErr)
@@ -499,3 +809,1300 @@ func TestCallResource_LogViews_MissingBucketId(t *testing.T) {
 	require.Equal(t, 400, sender.resp.Status)
 	require.Contains(t, string(sender.resp.Body), "BucketId")
 }
+
+func TestQueryData_ErrorGroups(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-1 * time.Hour)
+	stackTrace := "ValueError: bad input\nTraceback (most recent call last):\n  File \"/app/main.py\", line 6, in main\n    raise ValueError(\"bad input\")\nValueError: bad input"
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return([]*loggingpb.LogEntry{
+		{
+			InsertId:  "first",
+			Timestamp: timestamppb.New(from.Add(time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: stackTrace},
+		},
+		{
+			InsertId:  "second",
+			Timestamp: timestamppb.New(from.Add(2 * time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: stackTrace},
+		},
+		{
+			InsertId:  "plain",
+			Timestamp: timestamppb.New(from.Add(3 * time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "request served"},
+		},
+	}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "queryText": "severity >= ERROR"}`),
+				QueryType: errorGroupsQueryType,
+				RefID:     refID,
+				TimeRange: backend.TimeRange{
+					From: from,
+					To:   to,
+				},
+				MaxDataPoints: 20,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	require.Len(t, resp.Responses[refID].Frames, 1)
+
+	frame := resp.Responses[refID].Frames[0]
+	require.Equal(t, data.VisTypeTable, string(frame.Meta.PreferredVisualization))
+	require.Equal(t, 1, frame.Rows())
+	require.Equal(t, "ValueError: bad input", frame.Fields[1].At(0))
+	require.Equal(t, int64(2), frame.Fields[3].At(0))
+	require.Equal(t, "second", frame.Fields[7].At(0))
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_Patterns(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-1 * time.Hour)
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return([]*loggingpb.LogEntry{
+		{
+			InsertId:  "a",
+			Timestamp: timestamppb.New(from.Add(time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "request 1 served in 10ms"},
+		},
+		{
+			InsertId:  "b",
+			Timestamp: timestamppb.New(from.Add(2 * time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "request 2 served in 12ms"},
+		},
+		{
+			InsertId:  "c",
+			Timestamp: timestamppb.New(from.Add(3 * time.Minute)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "shutting down"},
+		},
+	}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "queryText": "", "maxPatterns": 1}`),
+				QueryType: patternsQueryType,
+				RefID:     refID,
+				TimeRange: backend.TimeRange{
+					From: from,
+					To:   to,
+				},
+				MaxDataPoints: 20,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	require.Len(t, resp.Responses[refID].Frames, 1)
+
+	frame := resp.Responses[refID].Frames[0]
+	require.Equal(t, 1, frame.Rows())
+	require.Equal(t, "request <NUM> served in <NUM>ms", frame.Fields[0].At(0))
+	require.Equal(t, int64(2), frame.Fields[1].At(0))
+	require.Equal(t, "a,b", frame.Fields[3].At(0))
+	require.Len(t, frame.Meta.Notices, 1)
+	require.Contains(t, frame.Meta.Notices[0].Text, "1 most frequent of 2 patterns")
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_LogContext(t *testing.T) {
+	anchorTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	entryAt := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId:  id,
+			Timestamp: timestamppb.New(anchorTime.Add(offset)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: id},
+		}
+	}
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Order == cloudlogging.OrderDescending && q.Limit == 2 && q.ProjectID == "testing"
+	})).Return([]*loggingpb.LogEntry{entryAt("before-1", -time.Second), entryAt("before-2", -2*time.Second)}, nil)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Order == cloudlogging.OrderAscending && q.Limit == 2 && q.ProjectID == "testing"
+	})).Return([]*loggingpb.LogEntry{entryAt("after-1", time.Second)}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "context": {"insertId": "anchor", "timestamp": "2024-03-01T12:00:00Z", "logName": "projects/testing/logs/stdout", "limit": 2}}`),
+				QueryType: logContextQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	frames := resp.Responses[refID].Frames
+	require.Len(t, frames, 3)
+	require.Equal(t, "before-2", frames[0].Name)
+	require.Equal(t, "before-1", frames[1].Name)
+	require.Equal(t, "after-1", frames[2].Name)
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_LogContextLogScope(t *testing.T) {
+	scope := &cloudlogging.LogScope{
+		ID:            "checkout",
+		Name:          "projects/testing/locations/global/logScopes/checkout",
+		ResourceNames: []string{"projects/testing", "projects/payments"},
+	}
+	client := mocks.NewAPI(t)
+	client.On("GetLogScope", mock.Anything, "testing", "checkout").Return(scope, nil)
+	// Both the before and after queries read the scope's resources
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return len(q.ResourceNames) == 2 && q.ResourceNames[1] == "projects/payments"
+	})).Return([]*loggingpb.LogEntry{}, nil).Twice()
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "logScope": "checkout", "context": {"insertId": "anchor", "timestamp": "2024-03-01T12:00:00Z"}}`),
+				QueryType: logContextQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_LogContextMissingAnchor(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "context": {"insertId": "anchor"}}`),
+				QueryType: logContextQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "timestamp")
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_LogContextInvalidResourceLabel(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "context": {"insertId": "anchor", "timestamp": "2024-03-01T12:00:00Z", "resourceLabels": {"pod_name=\"x\" OR logName:*": "api-1"}}}`),
+				QueryType: logContextQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid resource label")
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_LogContext(t *testing.T) {
+	anchorTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Order == cloudlogging.OrderDescending &&
+			strings.Contains(q.Filter, `resource.labels.pod_name="api-1"`)
+	})).Return([]*loggingpb.LogEntry{{
+		InsertId:  "before",
+		Timestamp: timestamppb.New(anchorTime.Add(-time.Second)),
+		Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "starting"},
+	}}, nil)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Order == cloudlogging.OrderAscending
+	})).Return([]*loggingpb.LogEntry{}, nil)
+
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logContext",
+		URL:  "logContext?ProjectId=my-project&InsertId=anchor&Timestamp=2024-03-01T12%3A00%3A00Z&resource.labels.pod_name=api-1",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 200, sender.resp.Status)
+
+	var result logContextResponse
+	require.NoError(t, json.Unmarshal(sender.resp.Body, &result))
+	require.Len(t, result.Before, 1)
+	require.Equal(t, "before", result.Before[0].ID)
+	require.Equal(t, "starting", result.Before[0].Message)
+	require.Empty(t, result.After)
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_LogContext_MissingInsertId(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logContext",
+		URL:  "logContext?ProjectId=my-project&Timestamp=2024-03-01T12%3A00%3A00Z",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 400, sender.resp.Status)
+	require.Contains(t, string(sender.resp.Body), "insertId")
+}
+
+func TestCallResource_Fields(t *testing.T) {
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.ProjectID == "my-project" && q.BucketId == "global/buckets/_Default" && q.Limit == fieldSampleSize
+	})).Return([]*loggingpb.LogEntry{{
+		InsertId: "a",
+		Resource: &monitoredres.MonitoredResource{
+			Type:   "k8s_container",
+			Labels: map[string]string{"pod_name": "api-1"},
+		},
+	}, {
+		InsertId: "b",
+		Resource: &monitoredres.MonitoredResource{
+			Type:   "k8s_container",
+			Labels: map[string]string{"pod_name": "api-2"},
+		},
+	}}, nil).Once()
+
+	ds := &CloudLoggingDatasource{client: client}
+
+	// The second call is served from the cache
+	for i := 0; i < 2; i++ {
+		sender := &responseSender{}
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+			Path: "fields",
+			URL:  "fields?ProjectId=my-project&BucketId=global%2Fbuckets%2F_Default&Limit=1",
+		}, sender)
+
+		require.NoError(t, err)
+		require.NotNil(t, sender.resp)
+		require.Equal(t, 200, sender.resp.Status)
+
+		var fields []cloudlogging.FieldSummary
+		require.NoError(t, json.Unmarshal(sender.resp.Body, &fields))
+		require.Contains(t, fields, cloudlogging.FieldSummary{
+			Path:   "resource.type",
+			Type:   cloudlogging.FieldTypeString,
+			Count:  2,
+			Values: []cloudlogging.FieldValueCount{{Value: "k8s_container", Count: 2}},
+		})
+		require.Contains(t, fields, cloudlogging.FieldSummary{
+			Path:   "resource.labels.pod_name",
+			Type:   cloudlogging.FieldTypeString,
+			Count:  2,
+			Values: []cloudlogging.FieldValueCount{{Value: "api-1", Count: 1}},
+		})
+	}
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_Fields_InvalidLimit(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "fields",
+		URL:  "fields?ProjectId=my-project&Limit=many",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 400, sender.resp.Status)
+}
+
+func TestCallResource_LogNames(t *testing.T) {
+	logNames := []string{"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity", "projects/my-project/logs/stdout"}
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogNames", mock.Anything, "my-project", "global/buckets/my-bucket", "").Return(logNames, nil)
+
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logNames",
+		URL:  "logNames?ProjectId=my-project&BucketId=global%2Fbuckets%2Fmy-bucket",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 200, sender.resp.Status)
+
+	var result []string
+	require.NoError(t, json.Unmarshal(sender.resp.Body, &result))
+	require.Equal(t, logNames, result)
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_LogNames_MissingProjectId(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logNames",
+		URL:  "logNames",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 400, sender.resp.Status)
+}
+
+func TestCallResource_ResourceTypes(t *testing.T) {
+	client := mocks.NewAPI(t)
+	client.On("ListMonitoredResourceTypes", mock.Anything).Return(nil, errors.New("permission denied"))
+
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "resourceTypes",
+		URL:  "resourceTypes",
+	}, sender)
+
+	require.NoError(t, err)
+	require.NotNil(t, sender.resp)
+	require.Equal(t, 502, sender.resp.Status)
+	require.Contains(t, string(sender.resp.Body), "permission denied")
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_FieldValues(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-1 * time.Hour)
+
+	entry := func(namespace string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			Resource: &monitoredres.MonitoredResource{
+				Type:   "k8s_container",
+				Labels: map[string]string{"namespace_name": namespace},
+			},
+		}
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Filter == "(\nseverity >= ERROR\n) AND resource.labels.namespace_name:*" && q.Limit == fieldValuesSampleSize
+	})).Return([]*loggingpb.LogEntry{entry("prod"), entry("dev"), entry("prod"), entry("staging")}, nil).Once()
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	// The second query is served from the cache
+	for i := 0; i < 2; i++ {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{
+					JSON:      []byte(`{"projectId": "testing", "queryText": "severity >= ERROR", "fieldPath": "resource.labels.namespace_name", "maxValues": 2}`),
+					QueryType: fieldValuesQueryType,
+					RefID:     refID,
+					TimeRange: backend.TimeRange{
+						From: from,
+						To:   to,
+					},
+				},
+			},
+		})
+
+		require.NoError(t, err)
+		require.NoError(t, resp.Responses[refID].Error)
+		require.Len(t, resp.Responses[refID].Frames, 1)
+
+		frame := resp.Responses[refID].Frames[0]
+		require.Equal(t, 2, frame.Rows())
+		require.Equal(t, "dev", frame.Fields[0].At(0))
+		require.Equal(t, "prod", frame.Fields[0].At(1))
+		require.Equal(t, int64(2), frame.Fields[1].At(1))
+		require.Len(t, frame.Meta.Notices, 1)
+	}
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_FieldValues_InvalidPath(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "fieldPath": "severity OR true"}`),
+				QueryType: fieldValuesQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid field path")
+}
+
+func TestQueryData_Annotations(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-1 * time.Hour)
+	start := from.Add(10 * time.Minute).UTC().Truncate(time.Second)
+
+	entry := func(insertID string, version string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId:  insertID,
+			Timestamp: timestamppb.New(start),
+			Labels:    map[string]string{"env": "prod", "version": version},
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "deployed " + version},
+		}
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).
+		Return([]*loggingpb.LogEntry{entry("a", "v2"), entry("b", "v2"), entry("c", "v1")}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "queryText": "logName:deploys", "annotation": {"titleField": "labels.version", "tagFields": ["labels.env"], "maxAnnotations": 1}}`),
+				QueryType: annotationsQueryType,
+				RefID:     refID,
+				TimeRange: backend.TimeRange{
+					From: from,
+					To:   to,
+				},
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	require.Len(t, resp.Responses[refID].Frames, 1)
+
+	frame := resp.Responses[refID].Frames[0]
+	require.Equal(t, 1, frame.Rows())
+	require.Equal(t, []string{"time", "timeEnd", "title", "text", "tags"}, []string{
+		frame.Fields[0].Name, frame.Fields[1].Name, frame.Fields[2].Name, frame.Fields[3].Name, frame.Fields[4].Name,
+	})
+	require.Equal(t, start, frame.Fields[0].At(0))
+	require.Nil(t, frame.Fields[1].At(0))
+	require.Equal(t, "v2", frame.Fields[2].At(0))
+	require.Equal(t, "deployed v2", frame.Fields[3].At(0))
+	require.Equal(t, "prod", frame.Fields[4].At(0))
+	require.Len(t, frame.Meta.Notices, 1)
+	require.Equal(t, "Showing 1 of 2 annotations", frame.Meta.Notices[0].Text)
+}
+
+func TestQueryData_Annotations_InvalidField(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "annotation": {"titleField": "labels.a labels.b"}}`),
+				QueryType: annotationsQueryType,
+				RefID:     refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid field path")
+}
+
+// chunkSender records every chunk of a streamed resource response
+type chunkSender struct {
+	resps []*backend.CallResourceResponse
+}
+
+func (s *chunkSender) Send(resp *backend.CallResourceResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+func TestCallResource_Export(t *testing.T) {
+	entry := func(insertID string, message string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			InsertId:  insertID,
+			LogName:   "projects/my-project/logs/app",
+			Timestamp: timestamppb.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)),
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: message},
+		}
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogPages", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.ProjectID == "my-project" && q.Filter == "severity>=ERROR" && q.Limit == 3 &&
+			q.TimeRange.From == "2024-03-01T00:00:00Z" && q.TimeRange.To == "2024-03-02T00:00:00Z"
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(2).(func([]*loggingpb.LogEntry) error)
+		require.NoError(t, fn([]*loggingpb.LogEntry{entry("a", "first, with a comma"), entry("b", "second")}))
+		require.NoError(t, fn([]*loggingpb.LogEntry{entry("c", "third")}))
+	}).Return(nil)
+
+	ds := &CloudLoggingDatasource{client: client}
+
+	sender := &chunkSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "export",
+		URL:  "export?ProjectId=my-project&query=severity%3E%3DERROR&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z&format=csv&fields=insertId,message&limit=3",
+	}, sender)
+
+	require.NoError(t, err)
+	require.Len(t, sender.resps, 2)
+	require.Equal(t, 200, sender.resps[0].Status)
+	require.Equal(t, []string{"text/csv; charset=utf-8"}, sender.resps[0].Headers["Content-Type"])
+	require.Contains(t, sender.resps[0].Headers["Content-Disposition"][0], ".csv")
+	require.Equal(t, "insertId,message\na,\"first, with a comma\"\nb,second\n", string(sender.resps[0].Body))
+	require.Zero(t, sender.resps[1].Status)
+	require.Equal(t, "c,third\n", string(sender.resps[1].Body))
+}
+
+func TestCallResource_Export_Errors(t *testing.T) {
+	t.Run("invalid format", func(t *testing.T) {
+		ds := &CloudLoggingDatasource{client: mocks.NewAPI(t)}
+		sender := &chunkSender{}
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+			Path: "export",
+			URL:  "export?ProjectId=my-project&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z&format=xml",
+		}, sender)
+		require.NoError(t, err)
+		require.Len(t, sender.resps, 1)
+		require.Equal(t, 400, sender.resps[0].Status)
+	})
+
+	t.Run("limit above the ceiling", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("ListLogPages", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+			return q.Limit == maxExportEntries
+		}), mock.Anything).Return(nil)
+
+		ds := &CloudLoggingDatasource{client: client}
+		sender := &chunkSender{}
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+			Path: "export",
+			URL:  "export?ProjectId=my-project&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z&limit=1000000000",
+		}, sender)
+		require.NoError(t, err)
+		require.Len(t, sender.resps, 1)
+		require.Equal(t, 200, sender.resps[0].Status)
+		require.Equal(t, []string{"application/x-ndjson"}, sender.resps[0].Headers["Content-Type"])
+		require.Empty(t, sender.resps[0].Body)
+	})
+
+	t.Run("failure after the first chunk", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("ListLogPages", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			fn := args.Get(2).(func([]*loggingpb.LogEntry) error)
+			require.NoError(t, fn([]*loggingpb.LogEntry{{InsertId: "a"}}))
+		}).Return(errors.New("deadline exceeded"))
+
+		ds := &CloudLoggingDatasource{client: client}
+		sender := &chunkSender{}
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+			Path: "export",
+			URL:  "export?ProjectId=my-project&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z",
+		}, sender)
+		require.NoError(t, err)
+		require.Len(t, sender.resps, 1)
+		require.Equal(t, 200, sender.resps[0].Status)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		client := mocks.NewAPI(t)
+		client.On("ListLogPages", mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, q *cloudlogging.Query, fn func([]*loggingpb.LogEntry) error) error {
+			if err := fn([]*loggingpb.LogEntry{{InsertId: "a"}}); err != nil {
+				return err
+			}
+			return fn([]*loggingpb.LogEntry{{InsertId: "b"}})
+		})
+
+		ds := &CloudLoggingDatasource{client: client}
+		sender := &chunkSender{}
+		cancellingSender := backend.CallResourceResponseSenderFunc(func(resp *backend.CallResourceResponse) error {
+			cancel()
+			return sender.Send(resp)
+		})
+		err := ds.CallResource(ctx, &backend.CallResourceRequest{
+			Path: "export",
+			URL:  "export?ProjectId=my-project&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z",
+		}, cancellingSender)
+		require.NoError(t, err)
+		require.Len(t, sender.resps, 1)
+	})
+}
+
+func TestQueryData_LogScope(t *testing.T) {
+	scope := &cloudlogging.LogScope{
+		ID:   "checkout",
+		Name: "projects/my-project/locations/global/logScopes/checkout",
+		ResourceNames: []string{
+			"projects/my-project",
+			"projects/payments/locations/global/buckets/team-payments/views/_AllLogs",
+		},
+	}
+	client := mocks.NewAPI(t)
+	client.On("GetLogScope", mock.Anything, "my-project", "checkout").Return(scope, nil)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return len(q.ResourceNames) == 2 && q.ResourceNames[1] == scope.ResourceNames[1]
+	})).Return([]*loggingpb.LogEntry{}, nil).Once()
+
+	refID := "test"
+	request := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:  []byte(`{"projectId": "my-project", "logScope": "checkout"}`),
+				RefID: refID,
+			},
+		},
+	}
+
+	ds := &CloudLoggingDatasource{client: client}
+	resp, err := ds.QueryData(context.Background(), request)
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+
+	// The scope includes a bucket excluded by the log bucket filter
+	ds.access = newAccessFilter("", "!global/buckets/team-.*")
+	resp, err = ds.QueryData(context.Background(), request)
+	require.NoError(t, err)
+	require.ErrorIs(t, resp.Responses[refID].Error, errAccessDenied)
+	require.ErrorContains(t, resp.Responses[refID].Error, `log scope "checkout"`)
+}
+
+func TestQueryData_AscendingOrder(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-1 * time.Hour)
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, &cloudlogging.Query{
+		ProjectID: "testing",
+		Filter:    `resource.type = "testing"`,
+		Limit:     20,
+		TimeRange: struct {
+			From string
+			To   string
+		}{
+			From: from.Format(time.RFC3339),
+			To:   to.Format(time.RFC3339),
+		},
+		Order: cloudlogging.OrderAscending,
+	}).Return([]*loggingpb.LogEntry{
+		{InsertId: "oldest", Timestamp: timestamppb.New(from.Add(time.Minute))},
+		{InsertId: "newest", Timestamp: timestamppb.New(from.Add(2 * time.Minute))},
+	}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:  []byte(`{"projectId": "testing", "queryText": "resource.type = \"testing\"", "order": "ASC"}`),
+				RefID: refID,
+				TimeRange: backend.TimeRange{
+					From: from,
+					To:   to,
+				},
+				MaxDataPoints: 20,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	require.Len(t, resp.Responses[refID].Frames, 2)
+	require.Equal(t, "oldest", resp.Responses[refID].Frames[0].Name)
+	require.Equal(t, "newest", resp.Responses[refID].Frames[1].Name)
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_TimeShift(t *testing.T) {
+	to := time.Date(2024, 3, 12, 10, 0, 0, 0, time.UTC)
+	from := to.Add(-1 * time.Hour)
+	dayBefore := from.AddDate(0, 0, -1)
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.TimeRange.From == dayBefore.Format(time.RFC3339) && q.TimeRange.To == to.AddDate(0, 0, -1).Format(time.RFC3339)
+	})).Return([]*loggingpb.LogEntry{
+		{InsertId: "yesterday", Timestamp: timestamppb.New(dayBefore.Add(time.Minute))},
+	}, nil).Twice()
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.TimeRange.From == "2024-01-01T00:00:00Z" && q.TimeRange.To == "2024-01-02T00:00:00Z"
+	})).Return([]*loggingpb.LogEntry{}, nil).Once()
+
+	ds := CloudLoggingDatasource{client: client}
+	refID := "test"
+	run := func(model string) backend.DataResponse {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{
+					JSON:          []byte(model),
+					RefID:         refID,
+					TimeRange:     backend.TimeRange{From: from, To: to},
+					MaxDataPoints: 20,
+				},
+			},
+		})
+		require.NoError(t, err)
+		return resp.Responses[refID]
+	}
+
+	resp := run(`{"projectId": "testing", "timeShift": "1d"}`)
+	require.NoError(t, resp.Error)
+	require.Len(t, resp.Frames, 1)
+	require.Equal(t, dayBefore.Add(time.Minute), resp.Frames[0].Fields[0].At(0))
+
+	// Aligned timestamps overlay the dashboard's time range
+	resp = run(`{"projectId": "testing", "timeShift": "1d", "alignTimestamps": true}`)
+	require.NoError(t, resp.Error)
+	require.Equal(t, from.Add(time.Minute), resp.Frames[0].Fields[0].At(0))
+	require.Len(t, resp.Frames[0].Meta.Notices, 1)
+
+	// The absolute time range wins over the time shift
+	resp = run(`{"projectId": "testing", "timeShift": "1d", "timeRange": {"from": "2024-01-01T00:00:00Z", "to": "2024-01-02T00:00:00Z"}}`)
+	require.NoError(t, resp.Error)
+
+	resp = run(`{"projectId": "testing", "timeShift": "yesterday"}`)
+	require.ErrorContains(t, resp.Error, "invalid time shift")
+
+	resp = run(`{"projectId": "testing", "timeRange": {"from": "2024-01-02T00:00:00Z", "to": "2024-01-01T00:00:00Z"}}`)
+	require.ErrorContains(t, resp.Error, "from must be before to")
+}
+
+func TestQueryData_DedupEntries(t *testing.T) {
+	now := timestamppb.Now()
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return([]*loggingpb.LogEntry{
+		{LogName: "projects/testing/logs/app", InsertId: "a", Timestamp: now},
+		{LogName: "projects/testing/logs/app", InsertId: "a", Timestamp: now},
+		{LogName: "projects/testing/logs/app", InsertId: "b", Timestamp: now},
+	}, nil)
+
+	ds := CloudLoggingDatasource{client: client}
+	refID := "test"
+	run := func(model string) backend.DataResponse {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{JSON: []byte(model), RefID: refID}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Responses[refID].Error)
+		return resp.Responses[refID]
+	}
+
+	resp := run(`{"projectId": "testing"}`)
+	require.Len(t, resp.Frames, 3)
+
+	resp = run(`{"projectId": "testing", "dedupEntries": true}`)
+	require.Len(t, resp.Frames, 2)
+	require.Equal(t, "1 duplicate entries were removed", resp.Frames[0].Meta.Notices[0].Text)
+}
+
+func TestQueryData_DedupSplitEntries(t *testing.T) {
+	now := timestamppb.Now()
+	part := func(index int32, text string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			LogName:   "projects/testing/logs/app",
+			InsertId:  fmt.Sprintf("part-%d", index),
+			Timestamp: now,
+			Split:     &loggingpb.LogSplit{Uid: "split", Index: index, TotalSplits: 2},
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: text},
+		}
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return([]*loggingpb.LogEntry{
+		part(0, "a"), part(0, "a"), part(1, "b"), part(1, "b"),
+	}, nil)
+
+	ds := CloudLoggingDatasource{client: client}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			JSON:  []byte(`{"projectId": "testing", "dedupEntries": true, "groupSplitEntries": true}`),
+			RefID: refID,
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+
+	// Duplicated parts are removed before the parts are merged
+	frames := resp.Responses[refID].Frames
+	require.Len(t, frames, 1)
+	require.Equal(t, "ab", frames[0].Fields[1].At(0))
+	require.Equal(t, "2 duplicate entries were removed", frames[0].Meta.Notices[0].Text)
+}
+
+func TestQueryData_Limit(t *testing.T) {
+	entries := func(n int) []*loggingpb.LogEntry {
+		logs := make([]*loggingpb.LogEntry, n)
+		for i := range logs {
+			logs[i] = &loggingpb.LogEntry{InsertId: fmt.Sprintf("entry-%d", i), Timestamp: timestamppb.Now()}
+		}
+		return logs
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Limit == 20
+	})).Return(entries(20), nil).Once()
+	// Limits over a page are read page by page
+	client.On("ListLogPages", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Limit == 5000
+	}), mock.Anything).Return(func(_ context.Context, _ *cloudlogging.Query, fn func([]*loggingpb.LogEntry) error) error {
+		for i := 0; i < 5; i++ {
+			if err := fn(entries(1000)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Once()
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Limit == 100
+	})).Return(entries(100), nil).Once()
+
+	ds := &CloudLoggingDatasource{client: client}
+	refID := "test"
+	run := func(model string) backend.DataResponse {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{JSON: []byte(model), RefID: refID, MaxDataPoints: 20}},
+		})
+		require.NoError(t, err)
+		return resp.Responses[refID]
+	}
+
+	// Without a limit, the panel's data points are used, and reaching them
+	// isn't worth a notice
+	resp := run(`{"projectId": "testing"}`)
+	require.NoError(t, resp.Error)
+	require.Len(t, resp.Frames, 20)
+	require.Nil(t, resp.Frames[0].Meta.Notices)
+
+	resp = run(`{"projectId": "testing", "limit": 5000}`)
+	require.NoError(t, resp.Error)
+	require.Len(t, resp.Frames, 5000)
+	require.Contains(t, resp.Frames[0].Meta.Notices[0].Text, "limited to 5000 entries")
+
+	// The data source's maximum caps the limit
+	ds.maxEntries = 100
+	resp = run(`{"projectId": "testing", "limit": 5000}`)
+	require.NoError(t, resp.Error)
+	require.Len(t, resp.Frames, 100)
+	require.Contains(t, resp.Frames[0].Meta.Notices[0].Text, "limited to 100 entries")
+
+	resp = run(`{"projectId": "testing", "limit": -1}`)
+	require.ErrorContains(t, resp.Error, "invalid limit")
+}
+
+func TestQueryData_Sampling(t *testing.T) {
+	to := time.Date(2024, 3, 12, 10, 0, 0, 0, time.UTC)
+	from := to.Add(-4 * time.Hour)
+	entryAt := func(ts string) *loggingpb.LogEntry {
+		parsed, err := time.Parse(time.RFC3339Nano, ts)
+		require.NoError(t, err)
+		return &loggingpb.LogEntry{LogName: "projects/testing/logs/app", InsertId: ts, Timestamp: timestamppb.New(parsed)}
+	}
+
+	var mu sync.Mutex
+	limits := []int64{}
+	windows := []cloudlogging.Query{}
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.Anything).Return(func(_ context.Context, q *cloudlogging.Query) []*loggingpb.LogEntry {
+		mu.Lock()
+		limits = append(limits, q.Limit)
+		windows = append(windows, *q)
+		mu.Unlock()
+		return []*loggingpb.LogEntry{entryAt(q.TimeRange.From)}
+	}, nil).Times(4)
+
+	ds := &CloudLoggingDatasource{client: client}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			JSON:      []byte(`{"projectId": "testing", "limit": 10, "sampleWindows": 4}`),
+			RefID:     refID,
+			TimeRange: backend.TimeRange{From: from, To: to},
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+
+	require.ElementsMatch(t, []int64{3, 3, 2, 2}, limits)
+	// Windows don't overlap, so no entry is read twice
+	sort.Slice(windows, func(i, j int) bool { return windows[i].TimeRange.From < windows[j].TimeRange.From })
+	for i := 1; i < len(windows); i++ {
+		require.Less(t, windows[i-1].TimeRange.To, windows[i].TimeRange.From)
+	}
+	require.Equal(t, "2024-03-12T10:00:00Z", windows[3].TimeRange.To)
+	frames := resp.Responses[refID].Frames
+	require.Len(t, frames, 4)
+	// Newest first, spread over the whole time range
+	for i, frame := range frames {
+		require.Equal(t, to.Add(time.Duration(-i-1)*time.Hour), frame.Fields[0].At(0))
+	}
+	require.Equal(t, "Entries were sampled from 4 windows of 1h0m0s each", frames[0].Meta.Notices[0].Text)
+
+	resp, err = ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{JSON: []byte(`{"projectId": "testing", "sampleWindows": 500}`), RefID: refID}},
+	})
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid sample windows")
+}
+
+func TestQueryData_ResponseBudget(t *testing.T) {
+	page := func(n int) []*loggingpb.LogEntry {
+		entries := make([]*loggingpb.LogEntry, n)
+		for i := range entries {
+			entries[i] = &loggingpb.LogEntry{
+				InsertId:  fmt.Sprintf("entry-%d", i),
+				Timestamp: timestamppb.Now(),
+				Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: strings.Repeat("x", 1000)},
+			}
+		}
+		return entries
+	}
+	pages := 0
+	client := mocks.NewAPI(t)
+	client.On("ListLogPages", mock.Anything, mock.Anything, mock.Anything).Return(func(_ context.Context, _ *cloudlogging.Query, fn func([]*loggingpb.LogEntry) error) error {
+		for i := 0; i < 5; i++ {
+			pages++
+			if err := fn(page(1000)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// About 1.5 pages fit in the budget
+	ds := &CloudLoggingDatasource{client: client, maxResponseBytes: 1500 * 2100}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{JSON: []byte(`{"projectId": "testing", "limit": 5000}`), RefID: refID}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+
+	// Reading stops at the page that went over the budget
+	require.Equal(t, 2, pages)
+	frames := resp.Responses[refID].Frames
+	require.Greater(t, len(frames), 1000)
+	require.Less(t, len(frames), 2000)
+	require.Len(t, frames[0].Meta.Notices, 1)
+	require.Equal(t, data.NoticeSeverityWarning, frames[0].Meta.Notices[0].Severity)
+	require.Contains(t, frames[0].Meta.Notices[0].Text, fmt.Sprintf("truncated to %d entries", len(frames)))
+}
+
+func TestQueryData_InvalidOrder(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:  []byte(`{"projectId": "testing", "order": "sideways"}`),
+				RefID: refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid order")
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_InvalidFilter(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:  []byte(`{"projectId": "testing", "queryText": "severity>=ERROR\n(resource.type=\"gce_instance\""}`),
+				RefID: refID,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "line 2, column 1")
+	client.AssertExpectations(t)
+}
+
+func TestCallResource_ValidateFilter(t *testing.T) {
+	ds := &CloudLoggingDatasource{client: mocks.NewAPI(t)}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "validateFilter",
+		URL:  "validateFilter?filter=" + url.QueryEscape(`severity>=ERROR OR`),
+	}, sender)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, sender.resp.Status)
+	var result filterValidationResponse
+	require.NoError(t, json.Unmarshal(sender.resp.Body, &result))
+	require.False(t, result.Valid)
+	require.Equal(t, &filterSyntaxError{Line: 1, Column: 17, Message: "expected an expression after OR"}, result.Error)
+
+	err = ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "validateFilter",
+		URL:  "validateFilter?filter=" + url.QueryEscape(`severity>=ERROR`),
+	}, sender)
+
+	require.NoError(t, err)
+	require.Equal(t, 200, sender.resp.Status)
+	require.JSONEq(t, `{"valid": true}`, string(sender.resp.Body))
+}
+
+func TestCheckHealth(t *testing.T) {
+	healthRequest := &backend.CheckHealthRequest{
+		PluginContext: backend.PluginContext{
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{
+				JSONData: []byte(`{"defaultProject": "my-project"}`),
+			},
+		},
+	}
+	allPermissions := []string{"logging.logEntries.list", "logging.buckets.list", "logging.views.list"}
+	checkStatuses := func(t *testing.T, result *backend.CheckHealthResult) []string {
+		var details healthDetails
+		require.NoError(t, json.Unmarshal(result.JSONDetails, &details))
+		require.Equal(t, "my-project", details.Project)
+		require.NotEmpty(t, details.VerboseMessage)
+		statuses := []string{}
+		for _, c := range details.Checks {
+			statuses = append(statuses, c.Status)
+		}
+		return statuses
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("TestToken", mock.Anything).Return(nil)
+		client.On("TestPermissions", mock.Anything, "my-project", allPermissions).Return(allPermissions, nil)
+		client.On("TestConnection", mock.Anything, "my-project").Return(nil)
+		client.On("ListProjectBuckets", mock.Anything, "my-project").Return([]cloudlogging.LogBucket{{ID: "global/buckets/_Default"}}, nil)
+		client.On("ListProjectBucketViews", mock.Anything, "my-project", "global/buckets/_Default").Return([]cloudlogging.LogView{{ID: "_AllLogs"}}, nil)
+		client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 1}).Return(&cloudlogging.ProjectPage{}, nil)
+
+		ds := &CloudLoggingDatasource{client: client}
+		result, err := ds.CheckHealth(context.Background(), healthRequest)
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusOk, result.Status)
+		require.Equal(t, "Successfully queried logs from GCP project my-project", result.Message)
+		require.Equal(t, []string{"ok", "ok", "ok", "ok", "ok"}, checkStatuses(t, result))
+	})
+
+	t.Run("empty project", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("TestToken", mock.Anything).Return(nil)
+		client.On("TestPermissions", mock.Anything, "my-project", allPermissions).Return([]string{"logging.logEntries.list"}, nil)
+		client.On("TestConnection", mock.Anything, "my-project").Return(cloudlogging.ErrNoEntries)
+		client.On("ListProjectBuckets", mock.Anything, "my-project").Return(nil, errors.New("forbidden"))
+		client.On("ListProjectBucketViews", mock.Anything, "my-project", "global/buckets/_Default").Return(nil, errors.New("forbidden"))
+		client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 1}).Return(&cloudlogging.ProjectPage{}, nil)
+
+		ds := &CloudLoggingDatasource{client: client}
+		result, err := ds.CheckHealth(context.Background(), healthRequest)
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusOk, result.Status)
+		require.Contains(t, result.Message, "no log entries yet")
+		require.Equal(t, []string{"ok", "warning", "warning", "warning", "ok"}, checkStatuses(t, result))
+		require.Contains(t, string(result.JSONDetails), "missing permission logging.buckets.list")
+	})
+
+	t.Run("no access", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("TestToken", mock.Anything).Return(nil)
+		client.On("TestPermissions", mock.Anything, "my-project", allPermissions).Return([]string{}, nil)
+		client.On("TestConnection", mock.Anything, "my-project").Return(cloudlogging.ErrNoEntries)
+		client.On("ListProjectBuckets", mock.Anything, "my-project").Return(nil, errors.New("forbidden"))
+		client.On("ListProjectBucketViews", mock.Anything, "my-project", "global/buckets/_Default").Return(nil, errors.New("forbidden"))
+		client.On("ListProjects", mock.Anything, cloudlogging.ProjectQuery{PageSize: 1}).Return(nil, errors.New("API not enabled"))
+
+		ds := &CloudLoggingDatasource{client: client}
+		result, err := ds.CheckHealth(context.Background(), healthRequest)
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.Contains(t, result.Message, "missing permission logging.logEntries.list")
+		require.Equal(t, []string{"ok", "error", "warning", "warning", "warning"}, checkStatuses(t, result))
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		client := mocks.NewAPI(t)
+		client.On("TestToken", mock.Anything).Return(errors.New("invalid_grant"))
+
+		ds := &CloudLoggingDatasource{client: client}
+		result, err := ds.CheckHealth(context.Background(), healthRequest)
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.Contains(t, result.Message, "invalid_grant")
+		require.Equal(t, []string{"error", "skipped", "skipped", "skipped", "skipped"}, checkStatuses(t, result))
+	})
+}
+
+func TestCallResource_LogScopes(t *testing.T) {
+	scopes := []cloudlogging.LogScope{
+		{ID: "_Default", ResourceNames: []string{"projects/my-project"}},
+		{ID: "cross-project", ResourceNames: []string{"projects/my-project", "projects/other-project"}},
+	}
+	client := mocks.NewAPI(t)
+	client.On("ListLogScopes", mock.Anything, "my-project").Return(scopes, nil)
+
+	ds := &CloudLoggingDatasource{
+		client: client,
+		access: newAccessFilter("my-.*", ""),
+	}
+
+	sender := &responseSender{}
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logScopes",
+		URL:  "logScopes?ProjectId=my-project",
+	}, sender)
+	require.NoError(t, err)
+	require.Equal(t, 200, sender.resp.Status)
+
+	var result []cloudlogging.LogScope
+	require.NoError(t, json.Unmarshal(sender.resp.Body, &result))
+	require.Len(t, result, 1)
+	require.Equal(t, "_Default", result[0].ID)
+
+	err = ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Path: "logScopes",
+		URL:  "logScopes?ProjectId=other-project",
+	}, sender)
+	require.NoError(t, err)
+	require.Equal(t, 403, sender.resp.Status)
+}
+
+func TestQueryData_TraceLogs(t *testing.T) {
+	spanTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	client := mocks.NewAPI(t)
+	client.On("ListLogs", mock.Anything, mock.MatchedBy(func(q *cloudlogging.Query) bool {
+		return q.Order == cloudlogging.OrderAscending &&
+			q.ProjectID == "testing" &&
+			q.Filter == "trace=\"projects/testing/traces/4bf92f3577b34da6a3ce929d0e0e4736\"" &&
+			q.TimeRange.From == "2024-03-01T11:00:00Z" &&
+			q.TimeRange.To == "2024-03-01T13:00:01Z"
+	})).Return([]*loggingpb.LogEntry{
+		{
+			InsertId:  "a",
+			Timestamp: timestamppb.New(spanTime),
+			Severity:  ltype.LogSeverity_INFO,
+			Trace:     "projects/testing/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:    "00f067aa0ba902b7",
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "handling request"},
+		},
+		{
+			InsertId:  "b",
+			Timestamp: timestamppb.New(spanTime.Add(time.Millisecond)),
+			Severity:  ltype.LogSeverity_ERROR,
+			Trace:     "projects/testing/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:    "53995c3f42cd8ad8",
+			Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "query failed"},
+		},
+	}, nil)
+
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:      []byte(`{"projectId": "testing", "traceId": "4bf92f3577b34da6a3ce929d0e0e4736", "limit": 100}`),
+				QueryType: traceLogsQueryType,
+				RefID:     refID,
+				TimeRange: backend.TimeRange{From: spanTime, To: spanTime.Add(time.Second)},
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, resp.Responses[refID].Error)
+	frames := resp.Responses[refID].Frames
+	require.Len(t, frames, 1)
+	require.Equal(t, 2, frames[0].Rows())
+	spans, _ := frames[0].FieldByName("spanId")
+	require.Equal(t, "00f067aa0ba902b7", spans.At(0))
+	require.Equal(t, "53995c3f42cd8ad8", spans.At(1))
+	levels, _ := frames[0].FieldByName("level")
+	require.Equal(t, "error", levels.At(1))
+	content, _ := frames[0].FieldByName("content")
+	require.Equal(t, "handling request", content.At(0))
+	client.AssertExpectations(t)
+}
+
+func TestQueryData_TraceLogsInvalidTraceID(t *testing.T) {
+	client := mocks.NewAPI(t)
+	ds := CloudLoggingDatasource{
+		client: client,
+	}
+	refID := "test"
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				JSON:          []byte(`{"projectId": "testing", "traceId": "abc\" OR \"x"}`),
+				QueryType:     traceLogsQueryType,
+				RefID:         refID,
+				MaxDataPoints: 100,
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.ErrorContains(t, resp.Responses[refID].Error, "invalid trace ID")
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+)
+
+// projectQueryFromParams reads the projects resource call parameters: a
+// search `query`, `label=key:value` (or `label=key` for any value) repeated
+// for each label, a `parent` folder or organization, and `pageSize` and
+// `pageToken` for pagination. The query is validated before being sent.
+func projectQueryFromParams(params url.Values) (cloudlogging.ProjectQuery, error) {
+	query := cloudlogging.ProjectQuery{
+		Search:    params.Get("query"),
+		Parent:    params.Get("parent"),
+		PageToken: params.Get("pageToken"),
+	}
+	for _, label := range params["label"] {
+		key, value, _ := strings.Cut(label, ":")
+		if query.Labels == nil {
+			query.Labels = map[string]string{}
+		}
+		query.Labels[key] = value
+	}
+	if pageSize := params.Get("pageSize"); pageSize != "" {
+		size, err := strconv.ParseInt(pageSize, 10, 32)
+		if err != nil || size < 0 {
+			return cloudlogging.ProjectQuery{}, fmt.Errorf("invalid pageSize %q", pageSize)
+		}
+		query.PageSize = int32(size)
+	}
+	if _, err := query.SearchFilter(); err != nil {
+		return cloudlogging.ProjectQuery{}, err
+	}
+	return query, nil
+}
+
+// listAllowedProjects lists a page of the projects matching the query that
+// can be queried. Pages thinned out by the access filter are topped up from
+// the next ones, so a page is only short if no more projects match.
+func (d *CloudLoggingDatasource) listAllowedProjects(ctx context.Context, client cloudlogging.API, query cloudlogging.ProjectQuery) (*cloudlogging.ProjectPage, error) {
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = cloudlogging.DefaultProjectPageSize
+	}
+	if pageSize > cloudlogging.MaxProjectPageSize {
+		pageSize = cloudlogging.MaxProjectPageSize
+	}
+
+	page := &cloudlogging.ProjectPage{Projects: []cloudlogging.Project{}}
+	for {
+		next, err := client.ListProjects(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		allowed := d.access.filterProjects(next.Projects)
+		page.Projects = append(page.Projects, allowed...)
+		page.NextPageToken = next.NextPageToken
+		if len(allowed) == len(next.Projects) || next.NextPageToken == "" || int32(len(page.Projects)) >= pageSize {
+			return page, nil
+		}
+		query.PageToken = next.NextPageToken
+		query.PageSize = pageSize - int32(len(page.Projects))
+	}
+}
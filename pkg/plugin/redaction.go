@@ -0,0 +1,32 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+)
+
+// newRedactor parses the redaction settings: field paths and patterns, one
+// per line, and the names of built-in patterns
+func newRedactor(conf config) (*cloudlogging.Redactor, error) {
+	return cloudlogging.NewRedactor(cloudlogging.RedactionRules{
+		Fields:   filterLines(conf.RedactFields),
+		Patterns: filterLines(conf.RedactPatterns),
+		Presets:  conf.RedactPresets,
+	})
+}
+
+// redactionNotice tells users how many values were redacted from the results
+const redactionNotice = "%d values were redacted by the data source's redaction rules"
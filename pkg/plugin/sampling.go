@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxSampleWindows is the maximum number of windows a time range can be
+	// split into for sampling
+	maxSampleWindows = 50
+	// sampleConcurrency is how many windows are read at once
+	sampleConcurrency = 8
+)
+
+// validateSampleWindows checks the number of windows of a sampled query,
+// where 0 and 1 turn sampling off
+func validateSampleWindows(windows int) error {
+	if windows < 0 || windows > maxSampleWindows {
+		return fmt.Errorf("invalid sample windows %d: must be between 0 and %d", windows, maxSampleWindows)
+	}
+	return nil
+}
+
+// sampleWindows splits a time range into n windows of the same length,
+// oldest first. Windows don't overlap, so an entry is only read once.
+func sampleWindows(tr backend.TimeRange, n int) []backend.TimeRange {
+	step := tr.To.Sub(tr.From) / time.Duration(n)
+	windows := make([]backend.TimeRange, n)
+	for i := range windows {
+		windows[i].From = tr.From.Add(time.Duration(i) * step)
+		windows[i].To = tr.From.Add(time.Duration(i+1)*step - time.Nanosecond)
+	}
+	// Don't lose the remainder of the division to the last window
+	windows[n-1].To = tr.To
+	return windows
+}
+
+// sampleLogs reads the entries of a query spread over its time range rather
+// than only those at one end of it: the time range is split into n windows,
+// read concurrently with an even share of the limit each, and their entries
+// are merged in the query's order.
+func sampleLogs(ctx context.Context, client cloudlogging.API, q cloudlogging.Query, tr backend.TimeRange, n int) ([]*loggingpb.LogEntry, error) {
+	if q.Limit < int64(n) {
+		n = int(q.Limit)
+	}
+	if n < 2 {
+		return client.ListLogs(ctx, &q)
+	}
+
+	windows := sampleWindows(tr, n)
+	results := make([][]*loggingpb.LogEntry, n)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(sampleConcurrency)
+	for i, w := range windows {
+		i, window := i, q
+		window.Limit = q.Limit / int64(n)
+		if int64(i) < q.Limit%int64(n) {
+			window.Limit++
+		}
+		window.TimeRange.From = w.From.Format(time.RFC3339Nano)
+		window.TimeRange.To = w.To.Format(time.RFC3339Nano)
+		g.Go(func() error {
+			entries, err := client.ListLogs(ctx, &window)
+			if err != nil {
+				return err
+			}
+			results[i] = entries
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	entries := []*loggingpb.LogEntry{}
+	for i := range results {
+		// Windows are oldest first, and entries newest first unless ascending
+		if q.Order != cloudlogging.OrderAscending {
+			i = len(results) - 1 - i
+		}
+		entries = append(entries, results[i]...)
+	}
+	return entries, nil
+}
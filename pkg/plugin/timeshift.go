@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// timeShiftPattern matches time shifts as Grafana writes them, such as `1h`,
+// `1d` or `2w`
+var timeShiftPattern = regexp.MustCompile(`^(\d+)\s*(s|m|h|d|w|M|y)$`)
+
+// timeRangeModel is an absolute time range replacing the dashboard's, with
+// RFC 3339 times
+type timeRangeModel struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// parseTimeShift returns a function moving times back by a time shift.
+// Days, weeks, months and years are calendar units, so shifting by `1d`
+// keeps the same wall clock time across daylight saving time changes.
+func parseTimeShift(shift string) (func(time.Time) time.Time, error) {
+	m := timeShiftPattern.FindStringSubmatch(shift)
+	if m == nil {
+		return nil, fmt.Errorf("invalid time shift %q: expected a number and a unit of s, m, h, d, w, M or y, such as 1d", shift)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time shift %q: %w", shift, err)
+	}
+	switch m[2] {
+	case "s":
+		return func(t time.Time) time.Time { return t.Add(-time.Duration(n) * time.Second) }, nil
+	case "m":
+		return func(t time.Time) time.Time { return t.Add(-time.Duration(n) * time.Minute) }, nil
+	case "h":
+		return func(t time.Time) time.Time { return t.Add(-time.Duration(n) * time.Hour) }, nil
+	case "d":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, -n) }, nil
+	case "w":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, -7*n) }, nil
+	case "M":
+		return func(t time.Time) time.Time { return t.AddDate(0, -n, 0) }, nil
+	default:
+		return func(t time.Time) time.Time { return t.AddDate(-n, 0, 0) }, nil
+	}
+}
+
+// queryTimeRange returns the time range to query: the query's absolute time
+// range if set, else the dashboard's shifted back by the query's time shift
+func queryTimeRange(q queryModel, tr backend.TimeRange) (backend.TimeRange, error) {
+	if q.TimeRange != nil && (q.TimeRange.From != "" || q.TimeRange.To != "") {
+		from, err := time.Parse(time.RFC3339Nano, q.TimeRange.From)
+		if err != nil {
+			return tr, fmt.Errorf("invalid time range from %q: expected an RFC 3339 timestamp", q.TimeRange.From)
+		}
+		to, err := time.Parse(time.RFC3339Nano, q.TimeRange.To)
+		if err != nil {
+			return tr, fmt.Errorf("invalid time range to %q: expected an RFC 3339 timestamp", q.TimeRange.To)
+		}
+		if !from.Before(to) {
+			return tr, fmt.Errorf("invalid time range: from must be before to")
+		}
+		return backend.TimeRange{From: from, To: to}, nil
+	}
+	if q.TimeShift == "" {
+		return tr, nil
+	}
+	shift, err := parseTimeShift(q.TimeShift)
+	if err != nil {
+		return tr, err
+	}
+	return backend.TimeRange{From: shift(tr.From), To: shift(tr.To)}, nil
+}
+
+// alignFrames moves the time fields of frames by offset, so entries of a
+// shifted time range overlay those of the dashboard's, and tells users of the
+// first frame by how much
+func alignFrames(frames data.Frames, offset time.Duration) {
+	if offset == 0 || len(frames) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			for i := 0; i < field.Len(); i++ {
+				switch v := field.At(i).(type) {
+				case time.Time:
+					field.Set(i, v.Add(offset))
+				case *time.Time:
+					if v != nil {
+						shifted := v.Add(offset)
+						field.Set(i, &shifted)
+					}
+				}
+			}
+		}
+	}
+
+	addNotice(frames, data.NoticeSeverityInfo, fmt.Sprintf("Timestamps were moved by %s to align with the dashboard's time range", offset))
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/GoogleCloudPlatform/cloud-logging-data-source-plugin/pkg/plugin/cloudlogging"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// traceLogsQuery handles the traceLogs query type, returning the entries of a
+// trace, or of one of its spans, in ascending timestamp order. explicitLimit
+// tells whether reaching limit is worth a notice, as from entryLimit.
+func (d *CloudLoggingDatasource) traceLogsQuery(ctx context.Context, q queryModel, filter string, timeRange backend.TimeRange, limit int64, explicitLimit bool, client cloudlogging.API) backend.DataResponse {
+	response := backend.DataResponse{}
+
+	base := cloudlogging.Query{
+		ProjectID:     q.ProjectID,
+		BucketId:      q.BucketId,
+		ViewId:        q.ViewId,
+		Filter:        filter,
+		Limit:         limit,
+		ResourceNames: q.resourceNames,
+	}
+	clientRequest, err := cloudlogging.TraceQuery(base, q.TraceID, q.SpanID, timeRange.From, timeRange.To)
+	if err != nil {
+		response.Error = fmt.Errorf("trace logs: %w", err)
+		return response
+	}
+
+	entries, err := client.ListLogs(ctx, clientRequest)
+	if err != nil {
+		response.Error = fmt.Errorf("query: %s", sanitizeErrorMessage(err))
+		return response
+	}
+
+	redacted := d.redactor.RedactEntries(entries)
+	response.Frames = append(response.Frames, traceLogsFrame(entries))
+	if redacted > 0 {
+		addNotice(response.Frames, data.NoticeSeverityInfo, fmt.Sprintf(redactionNotice, redacted))
+	}
+	if explicitLimit && int64(len(entries)) >= limit {
+		addNotice(response.Frames, data.NoticeSeverityWarning, fmt.Sprintf(truncationNotice, limit))
+	}
+	return response
+}
+
+// traceLogsFrame returns the entries of a trace as a single frame, with the
+// span of each entry as a column
+func traceLogsFrame(entries []*loggingpb.LogEntry) *data.Frame {
+	times := make([]time.Time, 0, len(entries))
+	bodies := make([]string, 0, len(entries))
+	levels := make([]string, 0, len(entries))
+	spans := make([]string, 0, len(entries))
+	ids := make([]string, 0, len(entries))
+	labels := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		body, err := cloudlogging.GetLogEntryMessage(entry)
+		if err != nil {
+			log.DefaultLogger.Warn("failed getting log message", "warning", err)
+		}
+		entryLabels, err := cloudlogging.GetLogLabels(entry).MarshalJSON()
+		if err != nil {
+			log.DefaultLogger.Warn("failed marshaling log labels", "warning", err)
+		}
+		times = append(times, entry.GetTimestamp().AsTime())
+		bodies = append(bodies, body)
+		levels = append(levels, cloudlogging.GetLogLevel(entry.GetSeverity()))
+		spans = append(spans, entry.GetSpanId())
+		ids = append(ids, entry.GetInsertId())
+		labels = append(labels, string(entryLabels))
+	}
+
+	frame := data.NewFrame("traceLogs",
+		data.NewField("time", nil, times),
+		data.NewField("content", nil, bodies),
+		data.NewField("level", nil, levels),
+		data.NewField("spanId", nil, spans),
+		data.NewField("id", nil, ids),
+		data.NewField("labels", nil, labels),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeLogs}
+	return frame
+}
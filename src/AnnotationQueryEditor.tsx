@@ -0,0 +1,103 @@
+/**
+ * Copyright 2022 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import React from 'react';
+import { QueryEditorProps } from '@grafana/data';
+import { InlineField, InlineFieldRow, Input } from '@grafana/ui';
+import { DataSource } from './datasource';
+import { LoggingQueryEditor } from './QueryEditor';
+import { AnnotationMapping, CloudLoggingOptions, Query } from './types';
+
+type Props = QueryEditorProps<DataSource, Query, CloudLoggingOptions>;
+
+/**
+ * Query editor for annotations: the log query, and the fields of the matching
+ * entries the backend maps to each annotation's title, text, tags and end time
+ */
+export function AnnotationQueryEditor(props: React.PropsWithChildren<Props>) {
+  const { query, onChange } = props;
+  const annotation = query.annotation ?? {};
+
+  const onMappingChange = (mapping: Partial<AnnotationMapping>) => {
+    onChange({ ...query, annotation: { ...annotation, ...mapping } });
+  };
+
+  return (
+    <>
+      <LoggingQueryEditor {...props} />
+      <InlineFieldRow>
+        <InlineField label="Title field" labelWidth={20} tooltip="Field of the annotation title, e.g. jsonPayload.deploy.version">
+          <Input
+            width={40}
+            value={annotation.titleField ?? ''}
+            placeholder="jsonPayload.deploy.version"
+            onChange={(e) => onMappingChange({ titleField: e.currentTarget.value })}
+          />
+        </InlineField>
+        <InlineField label="Text field" labelWidth={20} tooltip="Field of the annotation text, the log message if empty">
+          <Input
+            width={40}
+            value={annotation.textField ?? ''}
+            placeholder="Log message"
+            onChange={(e) => onMappingChange({ textField: e.currentTarget.value })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        <InlineField label="Tag fields" labelWidth={20} tooltip="Comma separated fields whose values tag the annotation, e.g. labels.env">
+          <Input
+            width={40}
+            value={(annotation.tagFields ?? []).join(',')}
+            placeholder="labels.env,resource.labels.cluster_name"
+            onChange={(e) =>
+              onMappingChange({
+                tagFields: e.currentTarget.value.split(',').map((field) => field.trim()),
+              })
+            }
+          />
+        </InlineField>
+        <InlineField
+          label="End time field"
+          labelWidth={20}
+          tooltip="Field of the end time of region annotations, as an RFC 3339 timestamp or seconds or milliseconds since the epoch"
+        >
+          <Input
+            width={40}
+            value={annotation.timeEndField ?? ''}
+            placeholder="jsonPayload.endTime"
+            onChange={(e) => onMappingChange({ timeEndField: e.currentTarget.value })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        <InlineField label="Max annotations" labelWidth={20} tooltip="Maximum number of annotations shown, up to 1000">
+          <Input
+            type="number"
+            width={20}
+            min={1}
+            max={1000}
+            value={annotation.maxAnnotations ?? ''}
+            placeholder="100"
+            onChange={(e) => {
+              const value = parseInt(e.currentTarget.value, 10);
+              onMappingChange({ maxAnnotations: isNaN(value) ? undefined : value });
+            }}
+          />
+        </InlineField>
+      </InlineFieldRow>
+    </>
+  );
+}
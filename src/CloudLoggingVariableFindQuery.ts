@@ -34,6 +34,10 @@ export default class CloudLoggingVariableFindQuery {
                     return this.handleBucketQuery(query)
                 case LogFindQueryScopes.Views:
                     return this.handleViewQuery(query)
+                case LogFindQueryScopes.LogNames:
+                    return this.handleLogNamesQuery(query)
+                case LogFindQueryScopes.ResourceTypes:
+                    return this.handleResourceTypesQuery()
                 default:
                     return [];
             }
@@ -90,4 +94,30 @@ export default class CloudLoggingVariableFindQuery {
             expandable: true,
         } as SelectableValue<string>));
     }
+
+    async handleLogNamesQuery({ projectId, bucketId }: CloudLoggingVariableQuery) {
+        let p = projectId
+        if (projectId.startsWith('$')) {
+            p = getTemplateSrv().replace(projectId)
+        }
+        let b = bucketId ?? ''
+        if (b.startsWith('$')) {
+            b = getTemplateSrv().replace(b)
+        }
+        const logNames = await this.datasource.getLogNames(p, b);
+        return (logNames).map((s) => ({
+            text: s,
+            value: s,
+            expandable: true,
+        } as SelectableValue<string>));
+    }
+
+    async handleResourceTypesQuery() {
+        const resourceTypes = await this.datasource.getResourceTypes();
+        return (resourceTypes).map((s) => ({
+            text: s,
+            value: s,
+            expandable: true,
+        } as SelectableValue<string>));
+    }
 }
@@ -17,9 +17,9 @@
 import { DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
 import { ConnectionConfig, GoogleAuthType } from '@grafana/google-sdk';
 import { DataSourcePicker } from '@grafana/runtime';
-import { Checkbox, Field, FieldSet, Input, SecretInput, Select, TextArea } from '@grafana/ui';
+import { Checkbox, Field, FieldSet, Input, MultiSelect, SecretInput, Select, TextArea } from '@grafana/ui';
 import React, { PureComponent } from 'react';
-import { authTypes, CloudLoggingOptions, DataSourceSecureJsonData } from './types';
+import { authTypes, CloudLoggingOptions, DataSourceSecureJsonData, redactionPresets } from './types';
 
 export type Props = DataSourcePluginOptionsEditorProps<CloudLoggingOptions, DataSourceSecureJsonData>;
 
@@ -283,6 +283,126 @@ const defaultProject = (props: Props) => {
           onPointerLeaveCapture={undefined}
         />
       </Field>
+      <Field
+        label="Restriction Filter"
+        description="A filter in the Logging query language ANDed into every query of this data source, including variables and autocompletion. Users can't override it. Leave empty to not restrict queries."
+      >
+        <TextArea
+          value={options.jsonData.restrictionFilter || ''}
+          placeholder={'resource.labels.namespace_name="team-a"'}
+          rows={2}
+          onChange={(e: React.ChangeEvent<HTMLTextAreaElement>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                restrictionFilter: e.target.value,
+              },
+            });
+          }}
+          onPointerEnterCapture={undefined}
+          onPointerLeaveCapture={undefined}
+        />
+      </Field>
+      <Field
+        label="Redacted Fields"
+        description="Field paths whose values are replaced by [REDACTED] in results, one per line. Nested fields are redacted too, as is metadata such as trace or sourceLocation."
+      >
+        <TextArea
+          value={options.jsonData.redactFields || ''}
+          placeholder={'jsonPayload.user\nhttpRequest.remoteIp\nlabels.email'}
+          rows={3}
+          onChange={(e: React.ChangeEvent<HTMLTextAreaElement>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                redactFields: e.target.value,
+              },
+            });
+          }}
+          onPointerEnterCapture={undefined}
+          onPointerLeaveCapture={undefined}
+        />
+      </Field>
+      <Field
+        label="Redaction Patterns"
+        description="Regular expressions whose matches are replaced by [REDACTED] in messages and labels, one per line."
+      >
+        <TextArea
+          value={options.jsonData.redactPatterns || ''}
+          placeholder={'api_key=\\w+'}
+          rows={3}
+          onChange={(e: React.ChangeEvent<HTMLTextAreaElement>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                redactPatterns: e.target.value,
+              },
+            });
+          }}
+          onPointerEnterCapture={undefined}
+          onPointerLeaveCapture={undefined}
+        />
+      </Field>
+      <Field label="Redaction Presets" description="Built-in patterns of sensitive values to redact.">
+        <MultiSelect
+          options={redactionPresets}
+          value={options.jsonData.redactPresets || []}
+          onChange={(values: Array<SelectableValue<string>>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                redactPresets: values.map((v) => v.value!),
+              },
+            });
+          }}
+        />
+      </Field>
+      <Field
+        label="Max Entries per Query"
+        description="Maximum number of entries a query can ask for with its limit. Defaults to 10000."
+      >
+        <Input
+          type="number"
+          min={1}
+          width={20}
+          value={options.jsonData.maxEntries ?? ''}
+          placeholder="10000"
+          onChange={(e: React.ChangeEvent<HTMLInputElement>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                maxEntries: e.target.value ? Number(e.target.value) : undefined,
+              },
+            });
+          }}
+        />
+      </Field>
+      <Field
+        label="Max Response Size (MiB)"
+        description="Approximate size of the log entries returned by a query, after which they're truncated with a notice. Defaults to 16 MiB."
+      >
+        <Input
+          type="number"
+          min={1}
+          width={20}
+          value={options.jsonData.maxResponseBytes ? options.jsonData.maxResponseBytes / (1 << 20) : ''}
+          placeholder="16"
+          onChange={(e: React.ChangeEvent<HTMLInputElement>) => {
+            onOptionsChange({
+              ...options,
+              jsonData: {
+                ...options.jsonData,
+                maxResponseBytes: e.target.value ? Math.round(Number(e.target.value) * (1 << 20)) : undefined,
+              },
+            });
+          }}
+        />
+      </Field>
     </>
   );
 };
@@ -16,12 +16,51 @@
 
 import React, { KeyboardEvent, useCallback, useEffect, useMemo, useRef, useState } from 'react';
 import { QueryEditorProps, SelectableValue } from '@grafana/data';
-import { Alert, InlineField, InlineFieldRow, LinkButton, Select, TextArea, Tooltip } from '@grafana/ui';
-import { DataSource } from './datasource';
-import { CloudLoggingOptions, defaultQuery, Query } from './types';
+import { Alert, InlineField, InlineFieldRow, InlineSwitch, Input, LinkButton, Select, TextArea, Tooltip } from '@grafana/ui';
+import { DataSource, escapeLabelValue } from './datasource';
+import { CloudLoggingOptions, defaultQuery, FieldSummary, LogBucket, LogContextQuery, orders, Query, queryTypes } from './types';
 
 type Props = QueryEditorProps<DataSource, Query, CloudLoggingOptions>;
 
+const msPerDay = 24 * 60 * 60 * 1000;
+
+/**
+ * Summarize the settings of a log bucket for its option in the bucket picker
+ */
+function describeBucket(bucket: LogBucket): string {
+  const details = [bucket.location, `${bucket.retentionDays} days retention`];
+  if (bucket.analyticsEnabled) {
+    details.push('analytics enabled');
+  }
+  if (bucket.linkedDatasets?.length) {
+    details.push(`linked to ${bucket.linkedDatasets.map(d => d.split('/').pop()).join(', ')}`);
+  }
+  if (bucket.locked) {
+    details.push('locked');
+  }
+  if (bucket.lifecycleState !== 'ACTIVE') {
+    details.push(bucket.lifecycleState);
+  }
+  return details.join(' · ');
+}
+
+/**
+ * Explain why the selected log bucket might return no entries over the time
+ * range, if it isn't active or doesn't retain entries that old
+ */
+function bucketWarning(bucket: LogBucket | undefined, from: number | undefined): string | undefined {
+  if (!bucket) {
+    return undefined;
+  }
+  if (bucket.lifecycleState !== 'ACTIVE') {
+    return `Log bucket ${bucket.id} is ${bucket.lifecycleState} and may not return entries`;
+  }
+  if (from !== undefined && bucket.retentionDays > 0 && from < Date.now() - bucket.retentionDays * msPerDay) {
+    return `Log bucket ${bucket.id} only retains entries for ${bucket.retentionDays} days, older entries won't be returned`;
+  }
+  return undefined;
+}
+
 /**
  * This is basically copied from {MQLQueryEditor} from the cloud-monitoring data source
  *
@@ -227,15 +266,20 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
   }, [datasource]);
 
   const [buckets, setBuckets] = useState<Array<SelectableValue<string>>>();
+  const [bucketDetails, setBucketDetails] = useState<LogBucket[]>([]);
   useEffect(() => {
     if (!query.projectId || query.projectId.startsWith('$')) { return; }
     if (projectsLoading) { return; }
     if (projectsForCurrentDs.length > 0 && !projectsForCurrentDs.some(p => p.value === query.projectId)) { return; }
-    datasource.getFilteredBuckets(query.projectId).then(res => {
-      setBuckets(res.map(bucket => ({
-        label: bucket,
-        value: bucket,
-      })));
+    datasource.getFilteredLogBuckets(query.projectId).then(res => {
+      // The empty entry stands for the project's default resource
+      const defaultResource = datasource.filterBuckets(['']).map(id => ({ label: id, value: id }));
+      setBucketDetails(res);
+      setBuckets([...defaultResource, ...res.map(bucket => ({
+        label: bucket.id,
+        value: bucket.id,
+        description: describeBucket(bucket),
+      }))]);
       setFetchError(undefined);
     }).catch(err => setFetchError(sanitizeFetchError(err)));
   }, [datasource, query.projectId, projectsLoading, projectsForCurrentDs]);
@@ -249,16 +293,90 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
     // Only fetch views if the selected bucket actually exists in the loaded buckets list
     const bucketExists = buckets.some(b => b.value === bid);
     if (query.projectId && !query.projectId.startsWith('$') && !bid.startsWith('$') && bucketExists) {
-      datasource.getLogBucketViews(query.projectId, `${bid}`).then(res => {
-        setViews(res.map(view => ({
-          label: view,
-          value: view,
-        })));
+      datasource.getLogViews(query.projectId, `${bid}`).then(res => {
+        setViews([{ label: '', value: '' }, ...res.map(view => ({
+          label: view.id,
+          value: view.id,
+          description: view.description || view.filter,
+        }))]);
         setFetchError(undefined);
       }).catch(err => setFetchError(sanitizeFetchError(err)));
     }
   }, [datasource, query.projectId, query.bucketId, buckets]);
 
+  const [logScopes, setLogScopes] = useState<Array<SelectableValue<string>>>();
+  useEffect(() => {
+    if (!query.projectId || query.projectId.startsWith('$')) { return; }
+    if (projectsLoading) { return; }
+    if (projectsForCurrentDs.length > 0 && !projectsForCurrentDs.some(p => p.value === query.projectId)) { return; }
+    datasource.getLogScopes(query.projectId).then(res => {
+      setLogScopes(res.map(scope => ({
+        label: scope.id,
+        value: scope.id,
+        description: scope.description,
+      })));
+    }).catch(() => {
+      // Log scopes are optional, so failing to list them isn't an error
+      setLogScopes([]);
+    });
+  }, [datasource, query.projectId, projectsLoading, projectsForCurrentDs]);
+
+  // Syntax error of the filter, as found by the backend once edited
+  const [filterError, setFilterError] = useState<string | undefined>();
+  const onQueryBlur = () => {
+    const filter = datasource.applyTemplateVariables(queryRef.current, {}).queryText ?? '';
+    datasource.validateFilter(filter).then(res => {
+      setFilterError(res.valid || !res.error
+        ? undefined
+        : `Line ${res.error.line}, column ${res.error.column}: ${res.error.message}`);
+    }).catch(() => {
+      // The query itself reports the error, if any
+      setFilterError(undefined);
+    });
+    onRunQuery();
+  };
+
+  // Fields sampled from recent entries, loaded when the field picker is
+  // first opened as sampling reads entries
+  const [fields, setFields] = useState<FieldSummary[]>();
+  const [fieldsLoading, setFieldsLoading] = useState(false);
+  const [selectedField, setSelectedField] = useState<FieldSummary>();
+  useEffect(() => {
+    setFields(undefined);
+    setSelectedField(undefined);
+  }, [datasource, query.projectId, query.bucketId, query.viewId]);
+
+  const loadFields = () => {
+    if (fields || fieldsLoading || !query.projectId || query.projectId.startsWith('$')) {
+      return;
+    }
+    setFieldsLoading(true);
+    datasource.getFields(query.projectId, query.bucketId, query.viewId)
+      .then(res => setFields(res))
+      .catch(err => setFetchError(sanitizeFetchError(err)))
+      .finally(() => setFieldsLoading(false));
+  };
+
+  /**
+   * Add a restriction on a field to the filter, on its value if given or
+   * else on its presence
+   */
+  const addFieldFilter = (path: string, value: string | null) => {
+    const restriction = value === null ? `${path}:*` : `${path}="${escapeLabelValue(value)}"`;
+    onChange({
+      ...query,
+      query: undefined,
+      queryText: effectiveQueryText ? `${effectiveQueryText}\n${restriction}` : restriction,
+    });
+    setSelectedField(undefined);
+    onRunQuery();
+  };
+
+  const onContextChange = (changes: Partial<LogContextQuery>) => onChange({
+    ...query,
+    context: { insertId: '', timestamp: '', ...query.context, ...changes },
+  });
+
   /**
    * Keep an up-to-date URI that links to the equivalent query in the GCP console
    */
@@ -315,6 +433,11 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
     return `https://console.cloud.google.com/logs/query?${queryParams.join('&')}`;
   }, [query, range]);
 
+  const selectedBucketWarning = bucketWarning(
+    bucketDetails.find(b => b.id === query.bucketId),
+    range?.from?.valueOf(),
+  );
+
   return (
     <>
       <InlineFieldRow>
@@ -329,6 +452,7 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
               projectId: e.value!,
               bucketId: query.bucketId && query.bucketId.startsWith('$') ? query.bucketId : "",
               viewId: query.viewId && query.viewId.startsWith('$') ? query.viewId : "",
+              logScope: query.logScope && query.logScope.startsWith('$') ? query.logScope : undefined,
             })}
             options={projectsForCurrentDs}
             isLoading={projectsLoading}
@@ -378,17 +502,248 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
             inputId={`${query.refId}-view`}
           />
         </InlineField>
+        <InlineField label='Log Scope' tooltip='Query the projects and views grouped by a log scope instead of the bucket and view'>
+          <Select
+            width={30}
+            allowCustomValue
+            isClearable
+            formatCreateLabel={(v) => `Use log scope: ${v}`}
+            onChange={e => onChange({
+              ...query,
+              logScope: e?.value,
+            })}
+            options={logScopes}
+            value={query.logScope ?? null}
+            placeholder="None"
+            inputId={`${query.refId}-logscope`}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        <InlineField label='Query Type'>
+          <Select
+            width={20}
+            onChange={e => onChange({ ...query, queryType: e.value || undefined })}
+            options={queryTypes}
+            value={query.queryType ?? ''}
+            inputId={`${query.refId}-querytype`}
+          />
+        </InlineField>
+        {!query.queryType && (
+          <InlineField label='Order' tooltip='Sort order of the entries by timestamp'>
+            <Select
+              width={16}
+              onChange={e => onChange({ ...query, order: e.value })}
+              options={orders}
+              value={query.order ?? 'desc'}
+              inputId={`${query.refId}-order`}
+            />
+          </InlineField>
+        )}
+        <InlineField label='Group Split Entries' tooltip='Reassemble entries that Cloud Logging split into several parts, as it does for entries over its size limit'>
+          <InlineSwitch
+            value={query.groupSplitEntries ?? false}
+            onChange={e => onChange({ ...query, groupSplitEntries: e.currentTarget.checked })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      {query.queryType === 'patterns' && (
+        <InlineFieldRow>
+          <InlineField label='Similarity' tooltip='Fraction of tokens, between 0 and 1, a message must share with a pattern to be grouped into it'>
+            <Input
+              type="number"
+              width={12}
+              min={0}
+              max={1}
+              step={0.1}
+              value={query.patternSimilarity ?? ''}
+              placeholder="0.4"
+              onChange={e => onChange({ ...query, patternSimilarity: e.currentTarget.value ? Number(e.currentTarget.value) : undefined })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+          <InlineField label='Max Patterns' tooltip='Number of patterns returned, the most frequent first'>
+            <Input
+              type="number"
+              width={12}
+              min={1}
+              value={query.maxPatterns ?? ''}
+              placeholder="50"
+              onChange={e => onChange({ ...query, maxPatterns: e.currentTarget.value ? Number(e.currentTarget.value) : undefined })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+        </InlineFieldRow>
+      )}
+      {query.queryType === 'logContext' && (
+        <InlineFieldRow>
+          <InlineField label='Insert ID' tooltip='Insert ID of the entry whose surrounding entries are returned'>
+            <Input
+              width={28}
+              value={query.context?.insertId ?? ''}
+              onChange={e => onContextChange({ insertId: e.currentTarget.value })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+          <InlineField label='Timestamp' tooltip='Timestamp of the entry, as an RFC 3339 timestamp'>
+            <Input
+              width={32}
+              value={query.context?.timestamp ?? ''}
+              placeholder="2024-01-01T00:00:00.000000000Z"
+              onChange={e => onContextChange({ timestamp: e.currentTarget.value })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+          <InlineField label='Entries' tooltip='Number of entries returned before and after the entry, up to 1000'>
+            <Input
+              type="number"
+              width={12}
+              min={1}
+              max={1000}
+              value={query.context?.limit ?? ''}
+              placeholder="10"
+              onChange={e => onContextChange({ limit: e.currentTarget.value ? Number(e.currentTarget.value) : undefined })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+        </InlineFieldRow>
+      )}
+      {query.queryType === 'traceLogs' && (
+        <InlineFieldRow>
+          <InlineField label='Trace ID' tooltip='Trace whose entries are returned, as a trace ID or projects/<project>/traces/<id>, with the time range widened by an hour on both sides'>
+            <Input
+              width={40}
+              value={query.traceId ?? ''}
+              placeholder="4bf92f3577b34da6a3ce929d0e0e4736"
+              onChange={e => onChange({ ...query, traceId: e.currentTarget.value })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+          <InlineField label='Span ID' tooltip='Span of the trace to return the entries of, all spans if empty'>
+            <Input
+              width={24}
+              value={query.spanId ?? ''}
+              placeholder="00f067aa0ba902b7"
+              onChange={e => onChange({ ...query, spanId: e.currentTarget.value })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+        </InlineFieldRow>
+      )}
+      <InlineFieldRow>
+        <InlineField label='Time Shift' tooltip='Query the time range moved back by an amount such as 1h, 1d or 1w, e.g. to compare with the day before'>
+          <Input
+            width={12}
+            value={query.timeShift ?? ''}
+            placeholder="1d"
+            onChange={e => onChange({ ...query, timeShift: e.currentTarget.value })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+        <InlineField label='From' tooltip='Absolute start of the time range, as an RFC 3339 timestamp, replacing the dashboard time range and time shift'>
+          <Input
+            width={28}
+            value={query.timeRange?.from ?? ''}
+            placeholder="2024-01-01T00:00:00Z"
+            onChange={e => onChange({ ...query, timeRange: { from: e.currentTarget.value, to: query.timeRange?.to ?? '' } })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+        <InlineField label='To' tooltip='Absolute end of the time range, as an RFC 3339 timestamp'>
+          <Input
+            width={28}
+            value={query.timeRange?.to ?? ''}
+            placeholder="2024-01-02T00:00:00Z"
+            onChange={e => onChange({ ...query, timeRange: { from: query.timeRange?.from ?? '', to: e.currentTarget.value } })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+        <InlineField label='Limit' tooltip='Number of entries to read, instead of a number depending on the panel width, up to the maximum set on the data source'>
+          <Input
+            type="number"
+            width={12}
+            min={1}
+            value={query.limit ?? ''}
+            placeholder="auto"
+            onChange={e => onChange({ ...query, limit: e.currentTarget.value ? Number(e.currentTarget.value) : undefined })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+        <InlineField label='Sample Windows' tooltip='Split the time range into up to 50 windows read with an even share of the limit each, so entries are spread over the time range rather than only the latest ones'>
+          <Input
+            type="number"
+            width={12}
+            min={0}
+            max={50}
+            value={query.sampleWindows ?? ''}
+            placeholder="off"
+            onChange={e => onChange({ ...query, sampleWindows: e.currentTarget.value ? Number(e.currentTarget.value) : undefined })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+        <InlineField label='Dedup Entries' tooltip='Remove entries returned more than once, by log name, insert ID and timestamp, such as when a log scope includes a project and a bucket its logs are routed to'>
+          <InlineSwitch
+            value={query.dedupEntries ?? false}
+            onChange={e => onChange({ ...query, dedupEntries: e.currentTarget.checked })}
+          />
+        </InlineField>
+        <InlineField label='Align Timestamps' tooltip='Move the timestamps of a shifted or absolute time range so entries overlay the dashboard time range'>
+          <InlineSwitch
+            value={query.alignTimestamps ?? false}
+            onChange={e => onChange({ ...query, alignTimestamps: e.currentTarget.checked })}
+          />
+        </InlineField>
       </InlineFieldRow>
       {fetchError && (
         <Alert severity="error" title={fetchError} />
       )}
+      {selectedBucketWarning && (
+        <Alert severity="warning" title={selectedBucketWarning} />
+      )}
+      {filterError && (
+        <Alert severity="warning" title={`Invalid query: ${filterError}`} />
+      )}
+      <InlineFieldRow>
+        <InlineField label='Add Filter' tooltip='Fields found in a sample of recent entries, and their most frequent values'>
+          <Select
+            width={40}
+            isLoading={fieldsLoading}
+            onOpenMenu={loadFields}
+            onChange={e => setSelectedField(fields?.find(f => f.path === e.value))}
+            options={fields?.map(f => ({
+              label: f.path,
+              value: f.path,
+              description: `${f.type} · in ${f.count} sampled entries`,
+            }))}
+            value={selectedField?.path ?? null}
+            placeholder="Select Field"
+            inputId={`${query.refId}-field`}
+          />
+        </InlineField>
+        {selectedField && (
+          <InlineField label='Value'>
+            <Select<string | null>
+              width={40}
+              allowCustomValue
+              formatCreateLabel={(v) => `Use value: ${v}`}
+              onChange={e => addFieldFilter(selectedField.path, e.value ?? null)}
+              options={[
+                { label: 'Any value', value: null, description: 'Entries with the field' },
+                ...selectedField.values.map(v => ({ label: v.value, value: v.value, description: `${v.count} entries` })),
+              ]}
+              placeholder="Select Value"
+              inputId={`${query.refId}-fieldvalue`}
+            />
+          </InlineField>
+        )}
+      </InlineFieldRow>
       <TextArea
         name="Query"
         className="slate-query-field"
         value={effectiveQueryText}
         rows={10}
         placeholder="Enter a Cloud Logging query (Run with Shift+Enter)"
-        onBlur={onRunQuery}
+        onBlur={onQueryBlur}
         onChange={e => onChange({
           ...query,
           queryText: e.currentTarget.value,
@@ -408,6 +763,26 @@ export function LoggingQueryEditor({ datasource, query, range, onChange, onRunQu
           View in Cloud Logging
         </LinkButton>
       </Tooltip>
+      <Tooltip content='Download all matching entries over the time range, up to 100,000, as newline delimited JSON'>
+        <LinkButton
+          href={range && datasource.getExportUrl(query, range, 'ndjson')}
+          disabled={!range || !query.projectId}
+          icon='download-alt'
+          variant='secondary'
+        >
+          Export NDJSON
+        </LinkButton>
+      </Tooltip>
+      <Tooltip content='Download the timestamp, severity, log name, insert ID and message of all matching entries over the time range, up to 100,000, as CSV'>
+        <LinkButton
+          href={range && datasource.getExportUrl(query, range, 'csv')}
+          disabled={!range || !query.projectId}
+          icon='download-alt'
+          variant='secondary'
+        >
+          Export CSV
+        </LinkButton>
+      </Tooltip>
     </>
   );
 };
@@ -29,6 +29,9 @@ export class CloudLoggingVariableQueryEditor extends PureComponent<Props, Variab
         { value: LogFindQueryScopes.Projects, label: 'Projects' },
         { value: LogFindQueryScopes.Buckets, label: 'Buckets' },
         { value: LogFindQueryScopes.Views, label: 'Views' },
+        { value: LogFindQueryScopes.LogNames, label: 'Log Names' },
+        { value: LogFindQueryScopes.ResourceTypes, label: 'Resource Types' },
+        { value: LogFindQueryScopes.FieldValues, label: 'Field Values' },
     ];
 
     defaults: VariableScopeData = {
@@ -120,6 +123,7 @@ export class CloudLoggingVariableQueryEditor extends PureComponent<Props, Variab
 
         switch (queryType) {
             case LogFindQueryScopes.Buckets:
+            case LogFindQueryScopes.LogNames:
                 return (
                     <>
                         <VariableQueryField
@@ -158,6 +162,41 @@ export class CloudLoggingVariableQueryEditor extends PureComponent<Props, Variab
                             label="Bucket"
                         />
                     </>);
+            case LogFindQueryScopes.FieldValues:
+                return (
+                    <>
+                        <VariableQueryField
+                            allowCustomValue={true}
+                            value={this.state.projectId}
+                            options={[variableOptionGroup, ...this.state.projects.map((v: any) => ({
+                                value: `${v}`,
+                                label: `${v}`,
+                            }))]}
+                            onChange={(value) => this.onProjectChange(value)}
+                            label="Project"
+                        />
+                        <div className="gf-form">
+                            <span className="gf-form-label width-10 query-keyword">Field</span>
+                            <input
+                                className="gf-form-input width-21"
+                                placeholder="resource.labels.namespace_name"
+                                value={this.state.fieldPath ?? ''}
+                                onChange={(e) => this.setState({ fieldPath: e.currentTarget.value })}
+                                onBlur={() => this.onPropsChange()}
+                            />
+                        </div>
+                        <div className="gf-form">
+                            <span className="gf-form-label width-10 query-keyword">Filter</span>
+                            <input
+                                className="gf-form-input width-21"
+                                placeholder='resource.type="k8s_container"'
+                                value={this.state.filter ?? ''}
+                                onChange={(e) => this.setState({ filter: e.currentTarget.value })}
+                                onBlur={() => this.onPropsChange()}
+                            />
+                        </div>
+                    </>
+                );
             default:
                 return '';
         }
@@ -14,8 +14,8 @@
  * limitations under the License.
  */
 
-import { ArrayVector, DataFrame, DataSourcePluginMeta, FieldType, Labels, ScopedVars } from '@grafana/data';
-import { DataSourceWithBackend, TemplateSrv } from '@grafana/runtime';
+import { ArrayVector, DataFrame, DataSourcePluginMeta, dateTime, FieldType, Labels, ScopedVars, TimeRange } from '@grafana/data';
+import { config, DataSourceWithBackend, TemplateSrv } from '@grafana/runtime';
 import { GoogleAuthType } from '@grafana/google-sdk';
 import { random } from 'lodash';
 import { lastValueFrom, of } from 'rxjs';
@@ -56,6 +56,18 @@ describe('Google Cloud Logging Data Source', () => {
         });
     });
 
+    describe('getProjects', () => {
+        it('follows page tokens to list all projects', async () => {
+            const ds = makeDataSource();
+            const getResource = jest.spyOn(DataSourceWithBackend.prototype, 'getResource')
+                .mockResolvedValueOnce({ projects: [{ id: 'a' }, { id: 'b' }], nextPageToken: 'next' })
+                .mockResolvedValueOnce({ projects: [{ id: 'c' }] });
+            await expect(ds.getProjects('proj')).resolves.toEqual(['a', 'b', 'c']);
+            expect(getResource).toHaveBeenLastCalledWith('projects', { query: 'proj', pageSize: '500', pageToken: 'next' });
+            getResource.mockRestore();
+        });
+    });
+
     describe('filterProjects', () => {
         const allProjects = [
             'my-project-123',
@@ -240,6 +252,12 @@ describe('Google Cloud Logging Data Source', () => {
             const ds = makeDataSource({ logBucketFilter: 'nonexistent-.*' });
             expect(ds.filterBuckets(allBuckets)).toEqual([]);
         });
+
+        it('keeps the default resource only if its buckets pass', () => {
+            expect(makeDataSource({ logBucketFilter: 'global/buckets/.*' }).filterBuckets([''])).toEqual(['']);
+            expect(makeDataSource({ logBucketFilter: '!.*/_Default' }).filterBuckets([''])).toEqual([]);
+            expect(makeDataSource({ logBucketFilter: '.*my-app-logs' }).filterBuckets([''])).toEqual([]);
+        });
     });
 
     describe('logs to traces data links', () => {
@@ -366,6 +384,41 @@ describe('Google Cloud Logging Data Source', () => {
             expect(ds.applyTemplateVariables(query, {} as ScopedVars).projectId).toBe('');
         });
     });
+
+    describe('getExportUrl', () => {
+        const passthroughTemplateSrv = {
+            replace: (s?: string) => s ?? '',
+        } as unknown as TemplateSrv;
+        const range = {
+            from: dateTime('2024-03-01T00:00:00Z'),
+            to: dateTime('2024-03-02T00:00:00Z'),
+        } as TimeRange;
+        const appSubUrl = config.appSubUrl;
+
+        afterEach(() => {
+            config.appSubUrl = appSubUrl;
+        });
+
+        it('links to the export resource', () => {
+            config.appSubUrl = '';
+            const ds = makeDataSource({}, passthroughTemplateSrv);
+            const url = ds.getExportUrl({ refId: 'A', projectId: 'my-proj' } as Query, range, 'csv');
+            expect(url).toMatch(new RegExp(`^/api/datasources/uid/${ds.uid}/resources/export\\?`));
+            expect(url).toContain('ProjectId=my-proj');
+        });
+
+        it('includes the sub path Grafana is served under', () => {
+            config.appSubUrl = '/grafana';
+            const ds = makeDataSource({}, passthroughTemplateSrv);
+            const url = ds.getExportUrl({ refId: 'A', projectId: 'my-proj' } as Query, range, 'ndjson');
+            expect(url).toMatch(new RegExp(`^/grafana/api/datasources/uid/${ds.uid}/resources/export\\?`));
+        });
+
+        it('returns undefined without a project', () => {
+            const ds = makeDataSource({}, passthroughTemplateSrv);
+            expect(ds.getExportUrl({ refId: 'A' } as Query, range, 'csv')).toBeUndefined();
+        });
+    });
 });
 
 const makeDataSource = (overrides?: Partial<CloudLoggingOptions>, templateSrv?: TemplateSrv) => {
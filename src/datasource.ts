@@ -15,6 +15,7 @@
  */
 
 import {
+  AnnotationSupport,
   DataFrame,
   DataQueryRequest,
   DataQueryResponse,
@@ -23,16 +24,33 @@ import {
   FieldType,
   QueryFixAction,
   ScopedVars,
+  TimeRange,
 } from '@grafana/data';
-import { DataSourceWithBackend, getBackendSrv, getDataSourceSrv, getTemplateSrv, TemplateSrv } from '@grafana/runtime';
+import { config, DataSourceWithBackend, getBackendSrv, getDataSourceSrv, getTemplateSrv, TemplateSrv } from '@grafana/runtime';
 import { from, lastValueFrom, Observable } from 'rxjs';
 import { map, mergeMap } from 'rxjs/operators';
-import { CloudLoggingOptions, Query } from './types';
+import { AnnotationQueryEditor } from './AnnotationQueryEditor';
+import {
+  CloudLoggingOptions,
+  FieldSummary,
+  FilterValidationResult,
+  LogBucket,
+  LogScope,
+  LogView,
+  maxProjectPageSize,
+  ProjectListOptions,
+  ProjectPage,
+  Query,
+} from './types';
 import { CloudLoggingVariableSupport } from './variables';
 
 export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions> {
   authenticationType: string;
-  annotations = {};
+  annotations: AnnotationSupport<Query> = {
+    QueryEditor: AnnotationQueryEditor,
+    // Annotations are built by the backend from the fields mapped in the editor
+    prepareQuery: (anno) => (anno.target ? { ...anno.target, queryType: 'annotations' } : undefined),
+  };
 
   constructor(
     private instanceSettings: DataSourceInstanceSettings<CloudLoggingOptions>,
@@ -117,8 +135,8 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
   }
 
   /**
-   * Have the backend call `resourcemanager.projects.list` with our credentials,
-   * and return the IDs of all projects found.
+   * Have the backend call `resourcemanager.projects.search` with our credentials,
+   * and return the IDs of the first page of projects found.
    * When query is provided, results are filtered server-side.
    * The default (empty) call is cached so multiple query editors don't
    * trigger redundant backend requests.
@@ -130,14 +148,55 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
   getProjects(query?: string): Promise<string[]> {
     if (!query) {
       if (!this.defaultProjectsCache) {
-        this.defaultProjectsCache = this.getResource('projects').catch((err: unknown) => {
+        this.defaultProjectsCache = this.listAllProjects().catch((err: unknown) => {
           this.defaultProjectsCache = null;
           throw err;
         });
       }
       return this.defaultProjectsCache;
     }
-    return this.getResource('projects', { query });
+    return this.listAllProjects({ query });
+  }
+
+  /**
+   * List the IDs of all projects matching the options, page by page
+   */
+  private async listAllProjects(options: ProjectListOptions = {}): Promise<string[]> {
+    const ids: string[] = [];
+    let pageToken: string | undefined;
+    do {
+      const page = await this.listProjects({ ...options, pageSize: maxProjectPageSize, pageToken });
+      ids.push(...page.projects.map((p) => p.id));
+      pageToken = page.nextPageToken;
+    } while (pageToken);
+    return ids;
+  }
+
+  /**
+   * Have the backend search projects with our credentials, by ID or display
+   * name, labels (`key:value`, or `key` for any value) and parent folder or
+   * organization
+   *
+   * @returns A page of projects with their metadata, and the token of the next page if any
+   */
+  listProjects(options: ProjectListOptions = {}): Promise<ProjectPage> {
+    const params: Record<string, string | string[]> = {};
+    if (options.query) {
+      params.query = options.query;
+    }
+    if (options.labels?.length) {
+      params.label = options.labels;
+    }
+    if (options.parent) {
+      params.parent = options.parent;
+    }
+    if (options.pageSize) {
+      params.pageSize = `${options.pageSize}`;
+    }
+    if (options.pageToken) {
+      params.pageToken = options.pageToken;
+    }
+    return this.getResource('projects', params);
   }
 
   /**
@@ -179,11 +238,11 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
 
   /**
    * Have the backend call `projects.locations.buckets.list` with our credentials,
-   * and return the names of all log buckets found
+   * and return all log buckets found, in all locations
    *
-   * @returns List of discovered bucket names
+   * @returns List of discovered buckets, with their location, retention and analytics settings
    */
-  getLogBuckets(projectId: string): Promise<string[]> {
+  getLogBuckets(projectId: string): Promise<LogBucket[]> {
     return this.getResource(`logBuckets`, { "ProjectId": projectId });
   }
 
@@ -199,6 +258,9 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
    * 1. If include patterns exist, keep only buckets matching at least one.
    * 2. Remove any buckets matching any exclude pattern.
    * If no patterns are configured, all buckets pass through unchanged.
+   *
+   * The empty bucket is the project's default resource, which reads the
+   * `_Default` and `_Required` buckets, so it's kept only if both are.
    */
   filterBuckets(buckets: string[]): string[] {
     const raw = this.instanceSettings.jsonData.logBucketFilter;
@@ -235,43 +297,132 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
       }
     }
 
-    let result = buckets;
-
     // Step 1: If include patterns exist, keep only matching buckets
-    if (includePatterns.length > 0) {
-      result = result.filter((bucket: string) =>
-        includePatterns.some((r: RegExp) => r.test(bucket))
-      );
-    }
-
     // Step 2: Remove any buckets matching exclude patterns
-    if (excludePatterns.length > 0) {
-      result = result.filter((bucket: string) =>
-        !excludePatterns.some((r: RegExp) => r.test(bucket))
-      );
-    }
+    const allowed = (bucket: string): boolean =>
+      (includePatterns.length === 0 || includePatterns.some((r: RegExp) => r.test(bucket))) &&
+      !excludePatterns.some((r: RegExp) => r.test(bucket));
+    const defaultResourceBuckets = ['global/buckets/_Default', 'global/buckets/_Required'];
 
-    return result;
+    return buckets.filter((bucket: string) =>
+      bucket === '' ? defaultResourceBuckets.every(allowed) : allowed(bucket)
+    );
   }
 
   /**
    * Get log buckets from the API and apply the configured log bucket filter.
    */
+  async getFilteredLogBuckets(projectId: string): Promise<LogBucket[]> {
+    const buckets = await this.getLogBuckets(projectId);
+    const allowed = new Set(this.filterBuckets(buckets.map(b => b.id)));
+    return buckets.filter(b => allowed.has(b.id));
+  }
+
+  /**
+   * Get the IDs of the log buckets allowed by the configured log bucket filter,
+   * after an empty entry standing for the project's default resource.
+   */
   async getFilteredBuckets(projectId: string): Promise<string[]> {
     const buckets = await this.getLogBuckets(projectId);
-    return this.filterBuckets(buckets);
+    return this.filterBuckets(['', ...buckets.map(b => b.id)]);
   }
 
   /**
    * Have the backend call `projects.locations.buckets.views.list` with our credentials,
-   * and return the names of all views of the log bucket
+   * and return all views of the log bucket
    *
-   * @returns List of discovered bucket names
+   * @returns List of discovered views, with their filter
    */
-  getLogBucketViews(projectId: string, bucketId: string): Promise<string[]> {
+  getLogViews(projectId: string, bucketId: string): Promise<LogView[]> {
     return this.getResource(`logViews`, { "ProjectId": projectId, "BucketId": bucketId });
   }
 
+  /**
+   * Get the IDs of the views of a log bucket, after an empty entry standing
+   * for the bucket itself
+   */
+  async getLogBucketViews(projectId: string, bucketId: string): Promise<string[]> {
+    const views = await this.getLogViews(projectId, bucketId);
+    return ['', ...views.map(v => v.id)];
+  }
+
+  /**
+   * Have the backend call `logs.list` with our credentials, and return the
+   * names of the logs with entries in a project, or in a view of one of its buckets
+   *
+   * @returns List of full log names, e.g. `projects/my-project/logs/stdout`
+   */
+  getLogNames(projectId: string, bucketId?: string, viewId?: string): Promise<string[]> {
+    return this.getResource('logNames', { "ProjectId": projectId, "BucketId": bucketId ?? '', "ViewId": viewId ?? '' });
+  }
+
+  /**
+   * Have the backend list the log scopes of a project with our credentials,
+   * leaving out those grouping projects or buckets the data source can't query
+   *
+   * @returns Log scopes with the names of the projects and views they group
+   */
+  getLogScopes(projectId: string): Promise<LogScope[]> {
+    return this.getResource('logScopes', { "ProjectId": projectId });
+  }
+
+  /**
+   * Have the backend call `monitoredResourceDescriptors.list` with our credentials,
+   * and return the types of all monitored resources
+   *
+   * @returns List of resource types, e.g. `gce_instance`
+   */
+  getResourceTypes(): Promise<string[]> {
+    return this.getResource('resourceTypes');
+  }
+
+  /**
+   * Link to the backend's export of all entries matching a query over a time
+   * range, streamed as a file download. It includes Grafana's sub path, if
+   * served under one.
+   *
+   * @param fields  Fields to include, whole entries in NDJSON or default columns in CSV if empty
+   * @returns URL of the export, or undefined if the query has no project
+   */
+  getExportUrl(query: Query, range: TimeRange, format: 'ndjson' | 'csv', fields: string[] = []): string | undefined {
+    const { projectId, bucketId, viewId, logScope, queryText, order } = this.applyTemplateVariables(query, {});
+    if (!projectId) {
+      return undefined;
+    }
+    const params = new URLSearchParams({
+      ProjectId: projectId,
+      BucketId: bucketId ?? '',
+      ViewId: viewId ?? '',
+      LogScope: logScope ?? '',
+      query: queryText ?? '',
+      from: range.from.toISOString(),
+      to: range.to.toISOString(),
+      format,
+      fields: fields.join(','),
+      order: order ?? '',
+    });
+    return `${config.appSubUrl ?? ''}/api/datasources/uid/${this.uid}/resources/export?${params.toString()}`;
+  }
+
+  /**
+   * Have the backend parse a filter written in the Logging query language
+   *
+   * @returns Whether the filter is valid, and the location of the first syntax error if not
+   */
+  validateFilter(filter: string): Promise<FilterValidationResult> {
+    return this.getResource('validateFilter', { filter });
+  }
+
+  /**
+   * Have the backend sample recent entries of a project, bucket and view,
+   * and list the fields found in them for autocompletion
+   *
+   * @returns Field paths with their observed type and most frequent values
+   */
+  getFields(projectId: string, bucketId?: string, viewId?: string): Promise<FieldSummary[]> {
+    return this.getResource('fields', { "ProjectId": projectId, "BucketId": bucketId ?? '', "ViewId": viewId ?? '' });
+  }
+
   /**
    * After performing a query, attach logs-to-traces data links when a
    * tracing data source is configured in the "Logs to traces" settings.
@@ -367,6 +518,10 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
         this.templateSrv.replace(query.projectId, scopedVars) || (query.query ? this.defaultProjectSync() : ''),
       bucketId: this.templateSrv.replace(query.bucketId, scopedVars),
       viewId: this.templateSrv.replace(query.viewId, scopedVars),
+      logScope: this.templateSrv.replace(query.logScope, scopedVars),
+      timeShift: this.templateSrv.replace(query.timeShift, scopedVars),
+      traceId: this.templateSrv.replace(query.traceId, scopedVars),
+      spanId: this.templateSrv.replace(query.spanId, scopedVars),
     };
   }
 
@@ -423,6 +578,6 @@ export class DataSource extends DataSourceWithBackend<Query, CloudLoggingOptions
 // - \n ... the newline character
 // - \  ... the backslash character
 // - "  ... the double-quote character
-function escapeLabelValue(labelValue: string): string {
+export function escapeLabelValue(labelValue: string): string {
   return labelValue.replace(/\\/g, '\\\\').replace(/\n/g, '\\n').replace(/"/g, '\\"');
 }
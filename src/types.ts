@@ -51,9 +51,38 @@ export interface DataSourceOptionsExt extends DataSourceOptions {
   universeDomain?: string;
   projectListFilter?: string;
   logBucketFilter?: string;
+  restrictionFilter?: string;
+  redactFields?: string;
+  redactPatterns?: string;
+  redactPresets?: string[];
+  maxEntries?: number;
+  maxResponseBytes?: number;
   logsToTraces?: LogsToTracesOptions;
 }
 
+/**
+ * Anchor entry of the logContext query type
+ */
+export interface LogContextQuery {
+  insertId: string;
+  timestamp: string;
+  logName?: string;
+  resourceType?: string;
+  resourceLabels?: Record<string, string>;
+  limit?: number;
+}
+
+/**
+ * Mapping of log entry fields to annotations in the annotations query type
+ */
+export interface AnnotationMapping {
+  titleField?: string;
+  textField?: string;
+  tagFields?: string[];
+  timeEndField?: string;
+  maxAnnotations?: number;
+}
+
 /**
  * Query from Grafana
  */
@@ -63,8 +92,156 @@ export interface Query extends DataQuery {
   projectId: string;
   bucketId?: string;
   viewId?: string;
+  order?: 'asc' | 'desc';
+  groupSplitEntries?: boolean;
+  dedupEntries?: boolean;
+  /** Number of entries to read instead of the panel's max data points */
+  limit?: number;
+  /** Splits the time range into windows sharing the limit, to spread entries over it */
+  sampleWindows?: number;
+  patternSimilarity?: number;
+  maxPatterns?: number;
+  context?: LogContextQuery;
+  fieldPath?: string;
+  maxValues?: number;
+  annotation?: AnnotationMapping;
+  logScope?: string;
+  /** Moves the dashboard's time range back, e.g. `1d` */
+  timeShift?: string;
+  /** Absolute RFC 3339 time range replacing the dashboard's */
+  timeRange?: QueryTimeRange;
+  /** Moves the timestamps of a shifted time range to overlay the dashboard's */
+  alignTimestamps?: boolean;
+  /** Trace, and optionally span, whose entries the traceLogs query type returns */
+  traceId?: string;
+  spanId?: string;
+}
+
+/**
+ * Absolute time range of a query, with RFC 3339 times
+ */
+export interface QueryTimeRange {
+  from: string;
+  to: string;
+}
+
+/**
+ * Log bucket configuration, as returned by the backend
+ */
+export interface LogBucket {
+  /** Bucket as used in queries, e.g. `global/buckets/my-bucket` */
+  id: string;
+  location: string;
+  description?: string;
+  retentionDays: number;
+  locked: boolean;
+  /** Whether the bucket can be queried with SQL through Log Analytics */
+  analyticsEnabled: boolean;
+  /** ACTIVE unless the bucket is being created, updated or deleted */
+  lifecycleState: string;
+  kmsKeyName?: string;
+  /** BigQuery datasets linked to the bucket, if analytics are enabled */
+  linkedDatasets?: string[];
+}
+
+/**
+ * Log view configuration, as returned by the backend
+ */
+export interface LogView {
+  id: string;
+  description?: string;
+  filter?: string;
+}
+
+/**
+ * Log scope grouping projects and log views, as returned by the backend
+ */
+export interface LogScope {
+  id: string;
+  name: string;
+  description?: string;
+  resourceNames: string[];
 }
 
+/**
+ * Result of the backend's filter validation
+ */
+export interface FilterValidationResult {
+  valid: boolean;
+  error?: {
+    line: number;
+    column: number;
+    message: string;
+  };
+}
+
+export interface FieldSummary {
+  path: string;
+  type: 'string' | 'number' | 'bool';
+  count: number;
+  values: Array<{ value: string; count: number }>;
+}
+
+/**
+ * Metadata of a GCP project, as listed by the projects resource call
+ */
+export interface ProjectInfo {
+  id: string;
+  displayName: string;
+  number: string;
+  parent?: string;
+  labels?: Record<string, string>;
+}
+
+export interface ProjectPage {
+  projects: ProjectInfo[];
+  nextPageToken?: string;
+}
+
+export interface ProjectListOptions {
+  query?: string;
+  labels?: string[];
+  parent?: string;
+  pageSize?: number;
+  pageToken?: string;
+}
+
+/**
+ * Maximum page size of the projects resource call, see cloudlogging.MaxProjectPageSize
+ */
+export const maxProjectPageSize = 500;
+
+/**
+ * Built-in redaction patterns, see cloudlogging.RedactionRules
+ */
+export const redactionPresets = [
+  { label: 'Email addresses', value: 'emails' },
+  { label: 'Bearer tokens', value: 'bearerTokens' },
+  { label: 'Credit card numbers', value: 'creditCards' },
+  { label: 'IPv4 addresses', value: 'ipv4' },
+  { label: 'IPv6 addresses', value: 'ipv6' },
+];
+
+/**
+ * Query types of the query editor, see the query types of pkg/plugin. Queries
+ * without one return log entries.
+ */
+export const queryTypes: Array<SelectableValue<string>> = [
+  { label: 'Logs', value: '', description: 'Entries matching the query' },
+  { label: 'Error Groups', value: 'errorGroups', description: 'Error entries grouped by their stack trace' },
+  { label: 'Patterns', value: 'patterns', description: 'Messages grouped into patterns, with their counts over time' },
+  { label: 'Log Context', value: 'logContext', description: 'Entries logged around an entry by the same log and resource' },
+  { label: 'Trace Logs', value: 'traceLogs', description: 'Entries of a trace, or of one of its spans, oldest first' },
+];
+
+/**
+ * Sort orders of log entries by timestamp
+ */
+export const orders: Array<SelectableValue<'asc' | 'desc'>> = [
+  { label: 'Newest first', value: 'desc' },
+  { label: 'Oldest first', value: 'asc' },
+];
+
 /**
  * Query that basically gets all logs
  */
@@ -84,6 +261,9 @@ export interface CloudLoggingVariableQuery extends DataQuery {
   selectedQueryType: string;
   projectId: string;
   bucketId?: string;
+  viewId?: string;
+  fieldPath?: string;
+  filter?: string;
 }
 
 /**
@@ -93,6 +273,9 @@ export enum LogFindQueryScopes {
   Projects = 'projects',
   Buckets = 'buckets',
   Views = 'views',
+  LogNames = 'logNames',
+  ResourceTypes = 'resourceTypes',
+  FieldValues = 'fieldValues',
 }
 
 /**
@@ -105,5 +288,7 @@ export interface VariableScopeData {
   bucketId: string;
   viewId: string;
   projectId: string;
+  fieldPath?: string;
+  filter?: string;
   loading: boolean;
 }
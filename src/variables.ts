@@ -22,7 +22,7 @@ import { CustomVariableSupport, DataQueryRequest, DataQueryResponse } from '@gra
 import CloudLoggingVariableFindQuery from './CloudLoggingVariableFindQuery';
 import { CloudLoggingVariableQueryEditor } from './VariableQueryEditor';
 import { DataSource } from './datasource';
-import { CloudLoggingVariableQuery } from './types';
+import { CloudLoggingVariableQuery, LogFindQueryScopes, Query } from './types';
 
 export class CloudLoggingVariableSupport extends CustomVariableSupport<
     DataSource,
@@ -39,10 +39,36 @@ export class CloudLoggingVariableSupport extends CustomVariableSupport<
     editor = CloudLoggingVariableQueryEditor;
 
     query(request: DataQueryRequest<CloudLoggingVariableQuery>): Observable<DataQueryResponse> {
+        const target = request.targets[0];
+        if (target.selectedQueryType === LogFindQueryScopes.FieldValues) {
+            return this.fieldValuesQuery(request, target);
+        }
         const executeObservable = from(this.logVarFindQuery.execute(request.targets[0]));
         return from(this.datasource.ensureGCEDefaultProject()).pipe(
             mergeMap(() => executeObservable),
             map((data) => ({ data }))
         );
     }
+
+    /**
+     * Have the backend list the distinct values of a field over the dashboard time range
+     */
+    fieldValuesQuery(request: DataQueryRequest<CloudLoggingVariableQuery>, target: CloudLoggingVariableQuery): Observable<DataQueryResponse> {
+        const query: Query = {
+            refId: target.refId,
+            queryType: LogFindQueryScopes.FieldValues,
+            projectId: target.projectId,
+            bucketId: target.bucketId,
+            viewId: target.viewId,
+            queryText: target.filter ?? '',
+            fieldPath: target.fieldPath,
+        };
+        return from(this.datasource.ensureGCEDefaultProject()).pipe(
+            mergeMap(() => this.datasource.query({ ...request, targets: [query] } as DataQueryRequest<Query>)),
+            map((response) => ({
+                data: (response.data[0]?.fields[0]?.values.toArray() ?? [])
+                    .map((value: string) => ({ text: value, value })),
+            }))
+        );
+    }
 }